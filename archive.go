@@ -0,0 +1,310 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var archiveCmd = &command{
+	name:  "archive",
+	args:  "in.zip|in.tar|in.tar.gz",
+	short: "Fixes the JSON entries of a zip or tar archive, copying the other entries untouched",
+	long:  "The order and the metadata of the entries are preserved.",
+	flags: flag.NewFlagSet("archive", flag.ExitOnError),
+	files: true,
+}
+
+var archiveOutput = archiveCmd.flags.String("o", "", "write the fixed archive to this `file` (required unless -check)")
+var archiveCheck = archiveCmd.flags.Bool("check", false, "list the entries which aren't fixed instead of writing an archive\n(exits with status 1 if there is at least one)")
+var archiveExtensions = archiveCmd.flags.String("ext", ".json,.jsonc,.json5", "comma separated `extensions` of the entries to fix")
+var archiveCompat = addCompatFlag(archiveCmd.flags)
+
+func init() {
+	archiveCmd.run = runArchive
+}
+
+type archiveFormat int
+
+const (
+	zipFormat archiveFormat = iota
+	tarFormat
+	tarGzipFormat
+)
+
+func detectArchiveFormat(filename string) (archiveFormat, error) {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return zipFormat, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return tarFormat, nil
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return tarGzipFormat, nil
+	}
+	return 0, fmt.Errorf("unknown archive format for %q (expected .zip, .tar, .tar.gz or .tgz)", filename)
+}
+
+// archiveFixer fixes the JSON entries of an archive
+type archiveFixer struct {
+	config     *jsoncomma.Config
+	extensions []string
+	// name of the archive, used in messages
+	name string
+	// check only reports the unfixed entries in unfixed
+	check   bool
+	unfixed []string
+}
+
+func (a *archiveFixer) isJSON(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range a.extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// fixEntry returns the fixed content of the entry, or records it as unfixed
+// in check mode
+func (a *archiveFixer) fixEntry(name string, content []byte) ([]byte, error) {
+	var fixed bytes.Buffer
+	fixed.Grow(len(content))
+	if _, err := jsoncomma.Fix(a.config, bytes.NewReader(content), &fixed); err != nil {
+		return nil, fmt.Errorf("fixing %s:%s: %s", a.name, name, err)
+	}
+	if a.check && !bytes.Equal(content, fixed.Bytes()) {
+		a.unfixed = append(a.unfixed, name)
+	}
+	return fixed.Bytes(), nil
+}
+
+func runArchive(args []string) error {
+	if len(args) != 1 {
+		archiveCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	filename := args[0]
+
+	if *archiveOutput == "" && !*archiveCheck {
+		return fmt.Errorf("-o is required (or -check)")
+	}
+
+	format, err := detectArchiveFormat(filename)
+	if err != nil {
+		return err
+	}
+
+	a := &archiveFixer{
+		config: &jsoncomma.Config{Version: *archiveCompat},
+		name:   filename,
+		check:  *archiveCheck,
+	}
+	for _, ext := range strings.Split(*archiveExtensions, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		a.extensions = append(a.extensions, ext)
+	}
+
+	out := ioutil.Discard
+	var outfile *os.File
+	if !a.check {
+		if outFormat, err := detectArchiveFormat(*archiveOutput); err != nil || outFormat != format {
+			return fmt.Errorf("the output %q should have the same format as the input %q", *archiveOutput, filename)
+		}
+		if inStat, err := os.Stat(filename); err == nil {
+			if outStat, err := os.Stat(*archiveOutput); err == nil && os.SameFile(inStat, outStat) {
+				return fmt.Errorf("refusing to overwrite the input %q", filename)
+			}
+		}
+
+		outfile, err = os.Create(*archiveOutput)
+		if err != nil {
+			return err
+		}
+		out = outfile
+	}
+
+	switch format {
+	case zipFormat:
+		err = a.fixZip(filename, out)
+	case tarFormat, tarGzipFormat:
+		err = a.fixTarFile(filename, out, format == tarGzipFormat)
+	}
+
+	if outfile != nil {
+		if closeErr := outfile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(outfile.Name())
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.check {
+		for _, name := range a.unfixed {
+			fmt.Printf("%s:%s\n", filename, name)
+		}
+		if len(a.unfixed) > 0 {
+			return exitStatus(1)
+		}
+	}
+	return nil
+}
+
+func (a *archiveFixer) fixZip(filename string, out io.Writer) error {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w := zip.NewWriter(out)
+	if err := w.SetComment(r.Comment); err != nil {
+		return err
+	}
+
+	for _, entry := range r.File {
+		if entry.FileInfo().IsDir() || !a.isJSON(entry.Name) {
+			// as is, still compressed, so that it's identical
+			if err := w.Copy(entry); err != nil {
+				return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+			}
+			continue
+		}
+
+		src, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+		}
+		content, err := ioutil.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+		}
+		fixed, err := a.fixEntry(entry.Name, content)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(fixed, content) {
+			if err := w.Copy(entry); err != nil {
+				return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+			}
+			continue
+		}
+
+		header := entry.FileHeader
+		// computed again by the writer
+		header.CRC32 = 0
+		header.CompressedSize = 0
+		header.CompressedSize64 = 0
+		header.UncompressedSize = 0
+		header.UncompressedSize64 = 0
+
+		dst, err := w.CreateHeader(&header)
+		if err != nil {
+			return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+		}
+		if _, err := dst.Write(fixed); err != nil {
+			return fmt.Errorf("%s:%s: %s", filename, entry.Name, err)
+		}
+	}
+
+	return w.Close()
+}
+
+func (a *archiveFixer) fixTarFile(filename string, out io.Writer, gzipped bool) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var in io.Reader = f
+	if gzipped {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: gzip: %s", filename, err)
+		}
+		defer zr.Close()
+		in = zr
+
+		zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		zw.Header = zr.Header
+		if err := a.fixTar(in, zw); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	}
+
+	return a.fixTar(in, out)
+}
+
+func (a *archiveFixer) fixTar(in io.Reader, out io.Writer) error {
+	r := tar.NewReader(in)
+	w := tar.NewWriter(out)
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", a.name, err)
+		}
+
+		// TypeRegA is how older archives mark regular files (recent
+		// readers already turn it into TypeReg)
+		regular := header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA
+		if regular && a.isJSON(header.Name) {
+			content, err := ioutil.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("%s:%s: %s", a.name, header.Name, err)
+			}
+			content, err = a.fixEntry(header.Name, content)
+			if err != nil {
+				return err
+			}
+			header.Size = int64(len(content))
+			if err := w.WriteHeader(header); err != nil {
+				return fmt.Errorf("%s:%s: %s", a.name, header.Name, err)
+			}
+			if _, err := w.Write(content); err != nil {
+				return fmt.Errorf("%s:%s: %s", a.name, header.Name, err)
+			}
+			continue
+		}
+
+		if err := w.WriteHeader(header); err != nil {
+			return fmt.Errorf("%s:%s: %s", a.name, header.Name, err)
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("%s:%s: %s", a.name, header.Name, err)
+		}
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveEntry is an entry of the test archives
+type archiveEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+var archiveModTime = time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+
+var archiveEntries = []archiveEntry{
+	{name: "dir/", mode: os.ModeDir | 0755},
+	{name: "dir/manifest.json", content: "{\"a\": 1 \"b\": [2,]}", mode: 0640},
+	// only the entries with a JSON extension are touched
+	{name: "dir/notes.txt", content: strings.Repeat("[1 2] ", 100), mode: 0600},
+	{name: "UPPER.JSON", content: "[3 4]", mode: 0644},
+	{name: "fixed.json", content: "[" + strings.Repeat("5, 6, ", 100) + "7]", mode: 0644},
+}
+
+// archiveFixed is the content of the entries once fixed
+var archiveFixed = map[string]string{
+	"dir/manifest.json": "{\"a\": 1, \"b\": [2]}",
+	"dir/notes.txt":     strings.Repeat("[1 2] ", 100),
+	"UPPER.JSON":        "[3, 4]",
+	"fixed.json":        "[" + strings.Repeat("5, 6, ", 100) + "7]",
+}
+
+func writeTestZip(t *testing.T, filename string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.SetComment("the comment")
+	// not the level the writer uses by default, so that an entry which is
+	// compressed again is different
+	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flate.NoCompression)
+	})
+	for _, entry := range archiveEntries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Comment: "about " + entry.name}
+		header.Modified = archiveModTime
+		header.SetMode(entry.mode)
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(entry.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTar(t *testing.T, filename string, gzipped bool) {
+	var buf bytes.Buffer
+	var zw *gzip.Writer
+	w := tar.NewWriter(&buf)
+	if gzipped {
+		zw = gzip.NewWriter(&buf)
+		zw.Name = "bundle.tar"
+		w = tar.NewWriter(zw)
+	}
+	for _, entry := range archiveEntries {
+		header := &tar.Header{
+			Name:    entry.name,
+			Mode:    int64(entry.mode.Perm()),
+			ModTime: archiveModTime,
+			Uname:   "someone",
+			Size:    int64(len(entry.content)),
+		}
+		header.Typeflag = tar.TypeReg
+		if entry.mode.IsDir() {
+			header.Typeflag = tar.TypeDir
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry.content))
+	}
+	// links are copied as is
+	w.WriteHeader(&tar.Header{Name: "link.json", Typeflag: tar.TypeSymlink, Linkname: "fixed.json", ModTime: archiveModTime})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runArchiveWith runs the archive command with the flags, and returns its
+// output
+func runArchiveWith(t *testing.T, flags map[string]string, filename string) (string, error) {
+	for name, value := range flags {
+		archiveCmd.flags.Set(name, value)
+	}
+	defer func() {
+		archiveCmd.flags.VisitAll(func(f *flag.Flag) {
+			f.Value.Set(f.DefValue)
+		})
+	}()
+	var err error
+	out := withStdio(t, nil, func() {
+		err = runArchive([]string{filename})
+	})
+	return string(out), err
+}
+
+func TestArchiveZip(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		writeTestZip(t, "in.zip")
+		if _, err := runArchiveWith(t, map[string]string{"o": "out.zip"}, "in.zip"); err != nil {
+			t.Fatal(err)
+		}
+
+		in, err := zip.OpenReader("in.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		r, err := zip.OpenReader("out.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.Comment != "the comment" {
+			t.Errorf("expected the comment to be kept, got %q", r.Comment)
+		}
+		if len(r.File) != len(archiveEntries) {
+			t.Fatalf("expected %d entries, got %d", len(archiveEntries), len(r.File))
+		}
+		for i, f := range r.File {
+			entry := archiveEntries[i]
+			if f.Name != entry.name {
+				t.Errorf("entry %d: expected %q, got %q (the order should be kept)", i, entry.name, f.Name)
+				continue
+			}
+			method := zip.Deflate
+			if entry.mode.IsDir() {
+				// directories are always stored
+				method = zip.Store
+			}
+			if f.Mode() != entry.mode || !f.Modified.Equal(archiveModTime) || f.Comment != "about "+entry.name || f.Method != method {
+				t.Errorf("%s: the metadata changed: %s %s %q %d", f.Name, f.Mode(), f.Modified, f.Comment, f.Method)
+			}
+
+			// the entries which aren't changed are copied as they are
+			if f.Name == "dir/notes.txt" || f.Name == "fixed.json" {
+				original, copied := rawEntry(t, in.File[i]), rawEntry(t, f)
+				if !bytes.Equal(original, copied) || f.CRC32 != in.File[i].CRC32 {
+					t.Errorf("%s: expected the compressed entry to be copied, got %q instead of %q", f.Name, copied, original)
+				}
+			}
+			if entry.mode.IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("%s: %s", f.Name, err)
+			}
+			if string(content) != archiveFixed[f.Name] {
+				t.Errorf("%s: actual %q, expected %q", f.Name, content, archiveFixed[f.Name])
+			}
+		}
+	})
+}
+
+// rawEntry returns the compressed content of the zip entry
+func rawEntry(t *testing.T, f *zip.File) []byte {
+	r, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestArchiveTar(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		inTempDir(t, func(dir string) {
+			in, out := "in.tar", "out.tar"
+			if gzipped {
+				in, out = "in.tar.gz", "out.tgz"
+			}
+			writeTestTar(t, in, gzipped)
+			if _, err := runArchiveWith(t, map[string]string{"o": out}, in); err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := ioutil.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tarContent io.Reader = bytes.NewReader(raw)
+			if gzipped {
+				zr, err := gzip.NewReader(tarContent)
+				if err != nil {
+					t.Fatalf("expected the output to be gzip'd: %s", err)
+				}
+				if zr.Name != "bundle.tar" {
+					t.Errorf("expected the gzip header to be kept, got %q", zr.Name)
+				}
+				tarContent = zr
+			}
+
+			r := tar.NewReader(tarContent)
+			var names []string
+			for {
+				header, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, header.Name)
+				if !header.ModTime.Equal(archiveModTime) {
+					t.Errorf("%s: the modification time changed to %s", header.Name, header.ModTime)
+				}
+				if header.Typeflag == tar.TypeSymlink {
+					if header.Linkname != "fixed.json" {
+						t.Errorf("%s: the link changed to %q", header.Name, header.Linkname)
+					}
+					continue
+				}
+				if header.Uname != "someone" {
+					t.Errorf("%s: the owner changed to %q", header.Name, header.Uname)
+				}
+				if header.Typeflag == tar.TypeDir {
+					continue
+				}
+				content, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != archiveFixed[header.Name] || header.Size != int64(len(content)) {
+					t.Errorf("%s: actual %q (size %d), expected %q", header.Name, content, header.Size, archiveFixed[header.Name])
+				}
+			}
+			expected := "dir/ dir/manifest.json dir/notes.txt UPPER.JSON fixed.json link.json"
+			if strings.Join(names, " ") != expected {
+				t.Errorf("entries: actual %q, expected %q", strings.Join(names, " "), expected)
+			}
+		})
+	}
+}
+
+func TestArchiveCheck(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		writeTestZip(t, "in.zip")
+		writeTestTar(t, "in.tar", false)
+
+		for _, filename := range []string{"in.zip", "in.tar"} {
+			out, err := runArchiveWith(t, map[string]string{"check": "true"}, filename)
+			if err != exitStatus(1) {
+				t.Errorf("%s: expected the exit status 1, got %v", filename, err)
+			}
+			expected := filename + ":dir/manifest.json\n" + filename + ":UPPER.JSON\n"
+			if out != expected {
+				t.Errorf("%s: actual %q, expected %q", filename, out, expected)
+			}
+		}
+
+		// only the fixed entries are checked
+		out, err := runArchiveWith(t, map[string]string{"check": "true", "ext": "txt"}, "in.zip")
+		if err != exitStatus(1) || out != "in.zip:dir/notes.txt\n" {
+			t.Errorf("-ext txt: expected notes.txt and the exit status 1, got %q and %v", out, err)
+		}
+		out, err = runArchiveWith(t, map[string]string{"check": "true", "ext": ".md"}, "in.zip")
+		if err != nil || out != "" {
+			t.Errorf("-ext .md: expected nothing, got %q and %v", out, err)
+		}
+
+		// the output must have the same format, and not be the input
+		if _, err := runArchiveWith(t, map[string]string{"o": "out.tar"}, "in.zip"); err == nil {
+			t.Errorf("expected an error for a different output format")
+		}
+		if _, err := runArchiveWith(t, map[string]string{"o": "in.zip"}, "in.zip"); err == nil {
+			t.Errorf("expected an error when overwriting the input")
+		}
+	})
+}
+
+// older archives mark regular files with TypeRegA ('\x00')
+func TestArchiveTarTypeRegA(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		var buf bytes.Buffer
+		w := tar.NewWriter(&buf)
+		w.WriteHeader(&tar.Header{Name: "old.json", Typeflag: tar.TypeReg, Mode: 0644, Size: 5, ModTime: archiveModTime})
+		w.Write([]byte("[1 2]"))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the type flag, and the checksum of the header (octal, with the
+		// type flag counted as a space)
+		raw := buf.Bytes()
+		raw[156] = tar.TypeRegA
+		sum := 0
+		for i, b := range raw[:512] {
+			if i >= 148 && i < 156 {
+				b = ' '
+			}
+			sum += int(b)
+		}
+		copy(raw[148:156], fmt.Sprintf("%06o\x00 ", sum))
+		if err := ioutil.WriteFile("in.tar", raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := runArchiveWith(t, map[string]string{"o": "out.tar"}, "in.tar"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.Open("out.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer out.Close()
+		r := tar.NewReader(out)
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil || string(content) != "[1, 2]" {
+			t.Errorf("expected the entry to be fixed, got %q (%v)", content, err)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var cacheCmd = &command{
+	name:   "cache",
+	args:   "clean|dir",
+	short:  "Manages the cache of the files known to be fixed",
+	long:   "clean removes everything, dir prints where it is.",
+	flags:  flag.NewFlagSet("cache", flag.ExitOnError),
+	values: []string{"clean", "dir"},
+}
+
+func init() {
+	cacheCmd.run = runCache
+}
+
+func runCache(args []string) error {
+	if len(args) != 1 {
+		cacheCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "clean":
+		return os.RemoveAll(dir)
+	case "dir":
+		fmt.Println(dir)
+		return nil
+	}
+	return fmt.Errorf("unknown cache command %q, expected clean or dir", args[0])
+}
+
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the cache directory: %s", err)
+	}
+	return filepath.Join(dir, "jsoncomma"), nil
+}
+
+// fixedCache remembers the content of the files which are already fixed,
+// so that they can be skipped on the next runs. It's an empty file per
+// content, named after the hash of the content, the tool version and the
+// config (so that upgrading or changing the config invalidates it).
+//
+// A nil *fixedCache is a disabled cache. The cache is best effort: errors
+// are ignored, the worst that can happen is that files are fixed again.
+//
+// The entries which haven't been used for cacheMaxAge are pruned (at most
+// once every cachePruneInterval), so that the cache doesn't grow forever.
+type fixedCache struct {
+	dir string
+	// salt is everything other than the content which changes the output
+	salt []byte
+}
+
+const (
+	// cacheMaxAge is how long an unused entry is kept
+	cacheMaxAge = 30 * 24 * time.Hour
+	// cachePruneInterval is how often the old entries are looked for
+	cachePruneInterval = 24 * time.Hour
+	// cacheRefreshAge is how old the modification time of an entry must be
+	// for a hit to update it. It avoids a write on every hit.
+	cacheRefreshAge = time.Hour
+)
+
+// openCache returns the cache for the config, or nil if it can't be used
+func openCache(config *jsoncomma.Config) *fixedCache {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil
+	}
+	c := &fixedCache{
+		dir:  filepath.Join(dir, "fixed"),
+		salt: []byte(toolVersion() + "\x00" + configKey(config) + "\x00"),
+	}
+	c.prune(time.Now())
+	return c
+}
+
+// toolVersion identifies the build. Development builds don't have a version,
+// so we use the executable's size and modification time instead.
+func toolVersion() string {
+	v := versionLine()
+	if version != "<not specified>" {
+		return v
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return v
+	}
+	stat, err := os.Stat(exe)
+	if err != nil {
+		return v
+	}
+	return fmt.Sprintf("%s %d %d", v, stat.Size(), stat.ModTime().UnixNano())
+}
+
+// configKey describes everything in the config which changes the output
+func configKey(config *jsoncomma.Config) string {
+	return "version " + config.Version.Resolve().String()
+}
+
+func (c *fixedCache) path(content []byte) string {
+	h := sha256.New()
+	h.Write(c.salt)
+	h.Write(content)
+	sum := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.dir, sum[:2], sum[2:])
+}
+
+// isFixed returns true if content is known to be fixed
+func (c *fixedCache) isFixed(content []byte) bool {
+	if c == nil {
+		return false
+	}
+	path := c.path(content)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	// the modification time is when the entry was last used
+	if now := time.Now(); now.Sub(stat.ModTime()) > cacheRefreshAge {
+		os.Chtimes(path, now, now)
+	}
+	return true
+}
+
+// markFixed remembers that content is fixed
+func (c *fixedCache) markFixed(content []byte) {
+	if c == nil {
+		return
+	}
+	path := c.path(content)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(path, nil, 0644)
+}
+
+// prunedPath is the file whose modification time is the last time the cache
+// was pruned
+func (c *fixedCache) prunedPath() string {
+	return filepath.Join(c.dir, "pruned")
+}
+
+// prune removes the entries which haven't been used for cacheMaxAge, unless
+// it was already done in the last cachePruneInterval
+func (c *fixedCache) prune(now time.Time) {
+	if stat, err := os.Stat(c.prunedPath()); err == nil && now.Sub(stat.ModTime()) < cachePruneInterval {
+		return
+	}
+
+	dirs, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		// nothing to prune yet
+		return
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(c.dir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if now.Sub(entry.ModTime()) > cacheMaxAge {
+				os.Remove(filepath.Join(c.dir, dir.Name(), entry.Name()))
+			}
+		}
+	}
+	ioutil.WriteFile(c.prunedPath(), nil, 0644)
+	os.Chtimes(c.prunedPath(), now, now)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// withCacheDir runs f with the cache in a new temporary directory
+func withCacheDir(t *testing.T, f func(dir string)) {
+	home, err := ioutil.TempDir("", "jsoncomma-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	old, set := os.LookupEnv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", home)
+	defer func() {
+		if set {
+			os.Setenv("XDG_CACHE_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CACHE_HOME")
+		}
+	}()
+
+	dir, err := cacheDir()
+	if err != nil || !strings.HasPrefix(dir, home) {
+		t.Skip("the cache directory can't be moved with XDG_CACHE_HOME on this system")
+	}
+	f(dir)
+}
+
+func TestFixCache(t *testing.T) {
+	withCacheDir(t, func(string) {
+		inTempDir(t, func(string) {
+			writeFiles(t, map[string]string{"a.json": "[1, 2]"})
+
+			rows := []struct {
+				opts   fixOptions
+				cached int
+			}{
+				// remembers the file is fixed
+				{fixOptions{}, 0},
+				{fixOptions{}, 1},
+				{fixOptions{noCache: true}, 0},
+				// the key is the actual version of the heuristics
+				{fixOptions{version: jsoncomma.V1}, 1},
+				{fixOptions{version: jsoncomma.V2}, 0},
+				{fixOptions{version: jsoncomma.V2}, 1},
+			}
+			for i, row := range rows {
+				stats := newRunStats(false, ioutil.Discard)
+				fix([]string{"a.json"}, row.opts, stats)
+				if stats.failed != 0 || stats.cached != row.cached {
+					t.Errorf("run %d (%+v): %d cached, %d failed, expected %d cached", i, row.opts, stats.cached, stats.failed, row.cached)
+				}
+			}
+			expectFiles(t, map[string]string{"a.json": "[1, 2]"})
+
+			// a file which isn't fixed is never cached
+			writeFiles(t, map[string]string{"b.json": "[1 2]"})
+			stats := newRunStats(false, ioutil.Discard)
+			fix([]string{"b.json"}, fixOptions{}, stats)
+			if stats.cached != 0 || stats.changed != 1 {
+				t.Errorf("expected b.json to be fixed, got %d cached, %d changed", stats.cached, stats.changed)
+			}
+			expectFiles(t, map[string]string{"b.json": "[1, 2]"})
+		})
+	})
+}
+
+func TestCacheKey(t *testing.T) {
+	withCacheDir(t, func(string) {
+		content := []byte("[1, 2]")
+		defaultCache := openCache(&jsoncomma.Config{})
+		v1 := openCache(&jsoncomma.Config{Version: jsoncomma.V1})
+		v2 := openCache(&jsoncomma.Config{Version: jsoncomma.V2})
+		if defaultCache.path(content) != v1.path(content) {
+			t.Errorf("expected the default version and v1 to share the entries")
+		}
+		if v1.path(content) == v2.path(content) {
+			t.Errorf("expected -compat to change the key")
+		}
+		if v1.path(content) == v1.path([]byte("[1, 3]")) {
+			t.Errorf("expected the content to change the key")
+		}
+
+		v1.markFixed(content)
+		if !v1.isFixed(content) || v1.isFixed([]byte("[1, 3]")) || v2.isFixed(content) {
+			t.Errorf("expected only the content to be fixed, for v1")
+		}
+
+		var disabled *fixedCache
+		disabled.markFixed(content)
+		if disabled.isFixed(content) {
+			t.Errorf("expected a disabled cache to know nothing")
+		}
+	})
+}
+
+func TestCacheClean(t *testing.T) {
+	withCacheDir(t, func(dir string) {
+		cache := openCache(&jsoncomma.Config{})
+		cache.markFixed([]byte("[]"))
+		if _, err := os.Stat(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := runCache([]string{"clean"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected the cache to be removed, got %v", err)
+		}
+		if cache.isFixed([]byte("[]")) {
+			t.Errorf("expected the entry to be gone")
+		}
+		if err := runCache([]string{"unknown"}); err == nil {
+			t.Errorf("expected an error for an unknown command")
+		}
+	})
+}
+
+func TestCachePrune(t *testing.T) {
+	withCacheDir(t, func(string) {
+		cache := openCache(&jsoncomma.Config{})
+		old, recent, used := []byte("[1]"), []byte("[2]"), []byte("[3]")
+		for _, content := range [][]byte{old, recent, used} {
+			cache.markFixed(content)
+		}
+		now := time.Now()
+		cache.prune(now)
+		longAgo := now.Add(-cacheMaxAge - time.Hour)
+		os.Chtimes(cache.path(old), longAgo, longAgo)
+		os.Chtimes(cache.path(used), longAgo, longAgo)
+		// a hit refreshes the entry
+		if !cache.isFixed(used) {
+			t.Fatal("expected a hit")
+		}
+
+		// it was pruned just now, so nothing happens until the next interval
+		cache.prune(now)
+		if _, err := os.Stat(cache.path(old)); err != nil {
+			t.Errorf("expected no pruning before the interval: %s", err)
+		}
+
+		cache.prune(now.Add(cachePruneInterval + time.Minute))
+		if _, err := os.Stat(cache.path(old)); !os.IsNotExist(err) {
+			t.Errorf("expected the old entry to be pruned, got %v", err)
+		}
+		for _, content := range [][]byte{recent, used} {
+			if _, err := os.Stat(cache.path(content)); err != nil {
+				t.Errorf("expected %s to be kept: %s", content, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var completionCmd = &command{
+	name:   "completion",
+	args:   "bash|zsh|fish",
+	short:  "Prints the shell completion script for bash, zsh or fish",
+	flags:  flag.NewFlagSet("completion", flag.ExitOnError),
+	values: []string{"bash", "zsh", "fish"},
+}
+
+func init() {
+	completionCmd.run = runCompletion
+}
+
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		completionCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	switch args[0] {
+	case "bash":
+		return writeBashCompletion(os.Stdout)
+	case "zsh":
+		return writeZshCompletion(os.Stdout)
+	case "fish":
+		return writeFishCompletion(os.Stdout)
+	}
+	return fmt.Errorf("unknown shell %q, expected bash, zsh or fish", args[0])
+}
+
+// flagNames returns the names of the flags, prefixed with a dash
+func flagNames(flags *flag.FlagSet) []string {
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+// isBoolFlag returns true if the flag doesn't take a value (-flag instead
+// of -flag value)
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+// quoteShell quotes s for shells (single quotes)
+func quoteShell(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# bash completion for jsoncomma, generated by $ jsoncomma completion bash\n\n")
+	b.WriteString("_jsoncomma() {\n")
+	b.WriteString("\tlocal cur cmd words files\n")
+	b.WriteString("\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tcmd=\"${COMP_WORDS[1]}\"\n\n")
+
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\twords=%s\n", quoteShell(strings.Join(append(commandNames(), flagNames(fixCmd.flags)...), " ")))
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\") $(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\t\treturn\n")
+	b.WriteString("\tfi\n\n")
+
+	b.WriteString("\tcase \"$cmd\" in\n")
+	for _, cmd := range commands {
+		words := append(flagNames(cmd.flags), cmd.values...)
+		fmt.Fprintf(&b, "\t%s)\n", cmd.name)
+		fmt.Fprintf(&b, "\t\twords=%s\n", quoteShell(strings.Join(words, " ")))
+		fmt.Fprintf(&b, "\t\tfiles=%t\n", cmd.files)
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\t*)\n")
+	fmt.Fprintf(&b, "\t\twords=%s\n", quoteShell(strings.Join(flagNames(fixCmd.flags), " ")))
+	b.WriteString("\t\tfiles=true\n")
+	b.WriteString("\t\t;;\n")
+	b.WriteString("\tesac\n\n")
+
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("\tif [ \"$files\" = true ]; then\n")
+	b.WriteString("\t\tCOMPREPLY+=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n\n")
+	b.WriteString("complete -o filenames -F _jsoncomma jsoncomma\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshDescription escapes a flag usage so that it can be used in between
+// the brackets of a zsh _arguments spec
+func zshDescription(usage string) string {
+	usage = firstLine(usage)
+	replacer := strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`, "'", `'\''`)
+	return replacer.Replace(usage)
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("#compdef jsoncomma\n\n")
+	b.WriteString("# zsh completion for jsoncomma, generated by $ jsoncomma completion zsh\n\n")
+
+	writeArguments := func(cmd *command) {
+		b.WriteString("\t\t_arguments")
+		cmd.flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&b, " \\\n\t\t\t'-%s[%s]", f.Name, zshDescription(f.Usage))
+			if !isBoolFlag(f) {
+				fmt.Fprintf(&b, ":%s: ", f.Name)
+			}
+			b.WriteString("'")
+		})
+		if cmd.files {
+			b.WriteString(" \\\n\t\t\t'*:file:_files'")
+		} else if len(cmd.values) > 0 {
+			fmt.Fprintf(&b, " \\\n\t\t\t'1:%s:(%s)'", cmd.args, strings.Join(cmd.values, " "))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("_jsoncomma() {\n")
+	b.WriteString("\tlocal -a commands\n")
+	b.WriteString("\tcommands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t'%s:%s'\n", cmd.name, zshDescription(cmd.short))
+	}
+	b.WriteString("\t)\n\n")
+
+	b.WriteString("\tif (( CURRENT == 2 )); then\n")
+	b.WriteString("\t\t_describe -t commands 'jsoncomma command' commands\n")
+	b.WriteString("\t\t_files\n")
+	b.WriteString("\t\treturn\n")
+	b.WriteString("\tfi\n\n")
+
+	b.WriteString("\tlocal cmd=$words[2]\n")
+	b.WriteString("\tif (( ${commands[(I)$cmd:*]} )); then\n")
+	b.WriteString("\t\tshift words\n")
+	b.WriteString("\t\t(( CURRENT-- ))\n")
+	b.WriteString("\tfi\n\n")
+
+	b.WriteString("\tcase $cmd in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t%s)\n", cmd.name)
+		writeArguments(cmd)
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\t*)\n")
+	writeArguments(fixCmd)
+	b.WriteString("\t\t;;\n")
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("_jsoncomma \"$@\"\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# fish completion for jsoncomma, generated by $ jsoncomma completion fish\n\n")
+	b.WriteString("complete -c jsoncomma -f\n\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c jsoncomma -n __fish_use_subcommand -a %s -d %s\n", cmd.name, quoteShell(cmd.short))
+	}
+	// $ jsoncomma files... (same as fix)
+	fixCmd.flags.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&b, "complete -c jsoncomma -n __fish_use_subcommand %s\n", fishFlag(f))
+	})
+	b.WriteString("complete -c jsoncomma -n __fish_use_subcommand -F\n")
+
+	for _, cmd := range commands {
+		b.WriteString("\n")
+		condition := quoteShell("__fish_seen_subcommand_from " + cmd.name)
+		cmd.flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&b, "complete -c jsoncomma -n %s %s\n", condition, fishFlag(f))
+		})
+		if cmd.files {
+			fmt.Fprintf(&b, "complete -c jsoncomma -n %s -F\n", condition)
+		}
+		if len(cmd.values) > 0 {
+			fmt.Fprintf(&b, "complete -c jsoncomma -n %s -a %s\n", condition, quoteShell(strings.Join(cmd.values, " ")))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishFlag returns the options of fish's complete command for the flag
+func fishFlag(f *flag.Flag) string {
+	opts := fmt.Sprintf("-o %s -d %s", f.Name, quoteShell(firstLine(f.Usage)))
+	if !isBoolFlag(f) {
+		opts += " -r"
+	}
+	return opts
+}
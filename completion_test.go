@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	rows := []struct {
+		shell string
+		write func(w io.Writer) error
+		// each command name and flag name appears in the output in these
+		// formats (a flag in any of its formats)
+		command string
+		flag    []string
+	}{
+		{"bash", writeBashCompletion, "\t%s)\n", []string{" %s", "'%s"}},
+		{"zsh", writeZshCompletion, "\t\t'%s:", []string{"'%s["}},
+		{"fish", writeFishCompletion, "-a %s ", []string{"-o %s "}},
+	}
+	for _, row := range rows {
+		var out bytes.Buffer
+		if err := row.write(&out); err != nil {
+			t.Fatalf("%s: %s", row.shell, err)
+		}
+		script := out.String()
+		for _, cmd := range commands {
+			if !strings.Contains(script, strings.Replace(row.command, "%s", cmd.name, 1)) {
+				t.Errorf("%s: the command %q is missing", row.shell, cmd.name)
+			}
+			for _, name := range flagNames(cmd.flags) {
+				if row.shell == "fish" {
+					name = strings.TrimPrefix(name, "-")
+				}
+				found := false
+				for _, format := range row.flag {
+					found = found || strings.Contains(script, strings.Replace(format, "%s", name, 1))
+				}
+				if !found {
+					t.Errorf("%s: the flag %s of %q is missing", row.shell, name, cmd.name)
+				}
+			}
+		}
+	}
+}
+
+func TestCompletionValues(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeZshCompletion(&out); err != nil {
+		t.Fatal(err)
+	}
+	// the completion command completes the shells
+	if !strings.Contains(out.String(), "'1:bash|zsh|fish:(bash zsh fish)'") {
+		t.Errorf("expected the shells to be completed:\n%s", out.String())
+	}
+
+	if quoted := quoteShell("it's"); quoted != `'it'\''s'` {
+		t.Errorf("quoteShell: got %s", quoted)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// compression is the way a file is compressed. Compressed files are
+// decompressed before being fixed, and compressed again when they are
+// written back (in place or -o)
+type compression int
+
+const (
+	uncompressed compression = iota
+	gzipped
+	// bzip2 is only supported for reading (the standard library doesn't
+	// have a writer)
+	bzipped
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+func (c compression) String() string {
+	switch c {
+	case gzipped:
+		return "gzip"
+	case bzipped:
+		return "bzip2"
+	}
+	return "uncompressed"
+}
+
+// detectCompression looks at the first bytes of in (without consuming
+// them), and falls back on filename's extension
+func detectCompression(in *bufio.Reader, filename string) compression {
+	magic, _ := in.Peek(len(bzip2Magic))
+	if bytes.HasPrefix(magic, gzipMagic) {
+		return gzipped
+	}
+	if bytes.HasPrefix(magic, bzip2Magic) {
+		return bzipped
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz", ".gzip":
+		return gzipped
+	case ".bz2", ".bzip2":
+		return bzipped
+	}
+	return uncompressed
+}
+
+// fixStream fixes in (named filename) to out, decompressing in if needed.
+// If recompress is true, out is compressed the same way in was, otherwise
+// the output is always uncompressed.
+func fixStream(config *jsoncomma.Config, filename string, in io.Reader, out io.Writer, recompress bool) (jsoncomma.Result, error) {
+	bufin := bufio.NewReader(in)
+
+	switch detectCompression(bufin, filename) {
+	case gzipped:
+		zr, err := gzip.NewReader(bufin)
+		if err != nil {
+			return jsoncomma.Result{}, fmt.Errorf("gzip: %s", err)
+		}
+		defer zr.Close()
+
+		if !recompress {
+			return jsoncomma.Fix(config, zr, out)
+		}
+
+		zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+		if err != nil {
+			return jsoncomma.Result{}, err
+		}
+		// keep the original name and modification time
+		zw.Header = zr.Header
+		result, err := jsoncomma.Fix(config, zr, zw)
+		if err != nil {
+			zw.Close()
+			return result, err
+		}
+		return result, zw.Close()
+
+	case bzipped:
+		if recompress {
+			return jsoncomma.Result{}, fmt.Errorf("bzip2 files can only be read, use -stdout")
+		}
+		return jsoncomma.Fix(config, bzip2.NewReader(bufin), out)
+	}
+
+	return jsoncomma.Fix(config, bufin, out)
+}
+
+// decompress returns the decompressed content (named filename)
+func decompress(content []byte, filename string) ([]byte, error) {
+	bufin := bufio.NewReader(bytes.NewReader(content))
+
+	var r io.Reader
+	switch detectCompression(bufin, filename) {
+	case gzipped:
+		zr, err := gzip.NewReader(bufin)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %s", err)
+		}
+		defer zr.Close()
+		r = zr
+	case bzipped:
+		r = bzip2.NewReader(bufin)
+	default:
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("decompressing: %s", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func gzipBytes(t *testing.T, content string, name string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = name
+	zw.ModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectCompression(t *testing.T) {
+	gzipContent := string(gzipBytes(t, "[]", ""))
+	rows := []struct {
+		content  string
+		filename string
+		expected compression
+	}{
+		{"[1 2]", "a.json", uncompressed},
+		// the magic bytes win over the extension
+		{gzipContent, "a.json", gzipped},
+		{gzipContent, "", gzipped},
+		{"BZh91AY&SY", "a.json", bzipped},
+		// without magic bytes, the extension decides
+		{"", "a.json.gz", gzipped},
+		{"", "a.JSON.GZ", gzipped},
+		{"", "a.json.bz2", bzipped},
+		{"", "a.json", uncompressed},
+	}
+	for _, row := range rows {
+		in := bufio.NewReader(strings.NewReader(row.content))
+		if actual := detectCompression(in, row.filename); actual != row.expected {
+			t.Errorf("%q named %q: actual %s, expected %s", row.content, row.filename, actual, row.expected)
+		}
+		// nothing is consumed
+		if rest, _ := ioutil.ReadAll(in); string(rest) != row.content {
+			t.Errorf("%q named %q: the magic bytes were consumed", row.content, row.filename)
+		}
+	}
+}
+
+func TestFixStreamGzip(t *testing.T) {
+	in := gzipBytes(t, "[1 2,]", "a.json")
+
+	var out bytes.Buffer
+	result, err := fixStream(&jsoncomma.Config{}, "whatever", bytes.NewReader(in), &out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 1 || result.Removed != 1 {
+		t.Errorf("expected 1 comma inserted and 1 removed, got %+v", result)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("expected the output to be gzip'd: %s", err)
+	}
+	fixed, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fixed) != "[1, 2]" {
+		t.Errorf("actual %q, expected %q", fixed, "[1, 2]")
+	}
+	// the header is kept
+	if zr.Name != "a.json" || !zr.ModTime.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("expected the header to be kept, got %q %s", zr.Name, zr.ModTime)
+	}
+
+	// to stdout, the output is decompressed
+	out.Reset()
+	if _, err := fixStream(&jsoncomma.Config{}, "a.json.gz", bytes.NewReader(in), &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[1, 2]" {
+		t.Errorf("actual %q, expected %q", out.String(), "[1, 2]")
+	}
+
+	// a .gz which isn't gzip'd
+	if _, err := fixStream(&jsoncomma.Config{}, "a.json.gz", strings.NewReader("[1 2]"), &out, true); err == nil {
+		t.Errorf("expected an error for an invalid gzip file")
+	}
+	// bzip2 can't be written back
+	if _, err := fixStream(&jsoncomma.Config{}, "a.json.bz2", strings.NewReader("BZh9"), &out, true); err == nil {
+		t.Errorf("expected an error for bzip2 in place")
+	}
+}
+
+func TestFixfileGzip(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		if err := ioutil.WriteFile("a.json.gz", gzipBytes(t, "{\"a\": 1 \"b\": 2}", "a.json"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := fixfile(&jsoncomma.Config{}, nil, "a.json.gz"); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ioutil.ReadFile("a.json.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := decompress(raw, "a.json.gz")
+		if err != nil {
+			t.Fatalf("expected the file to be gzip'd back: %s", err)
+		}
+		if string(content) != "{\"a\": 1, \"b\": 2}" {
+			t.Errorf("actual %q", content)
+		}
+
+		// the decompressed content goes to stdout
+		stats := newRunStats(false, ioutil.Discard)
+		if err := ioutil.WriteFile("b.json.gz", gzipBytes(t, "[1 2]", ""), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out := withStdio(t, nil, func() {
+			fix([]string{"b.json.gz"}, fixOptions{tostdout: true, noCache: true}, stats)
+		})
+		if string(out) != "[1, 2]" {
+			t.Errorf("-stdout: actual %q", out)
+		}
+		if _, err := os.Stat("b.json.gz"); err != nil {
+			t.Error(err)
+		}
+	})
+}
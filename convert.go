@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var convertCmd = &command{
+	name:  "convert",
+	args:  "[file]",
+	short: "Converts a JSON-like file to YAML, TOML or strict JSON (on stdout)",
+	long: "Key order and numbers are kept as is, and so are the comments when the\n" +
+		"target format has some.",
+	flags: flag.NewFlagSet("convert", flag.ExitOnError),
+	files: true,
+}
+
+var convertTo = convertCmd.flags.String("to", "", "the target `format`: yaml, toml or json")
+var convertOutput = convertCmd.flags.String("o", "", "write to this `file` instead of stdout")
+var convertInput = addInputFlags(convertCmd.flags)
+
+var convertCompat = addCompatFlag(convertCmd.flags)
+
+func init() {
+	convertCmd.run = runConvert
+}
+
+// emitters convert a document to each target format
+var emitters = map[string]func(doc *jsoncomma.Document) ([]byte, error){
+	"yaml": emitYAML,
+	"toml": emitTOML,
+	"json": emitJSON,
+}
+
+func runConvert(args []string) error {
+	emit, ok := emitters[*convertTo]
+	if !ok {
+		return fmt.Errorf("unknown format %q, expected -to yaml, toml or json", *convertTo)
+	}
+
+	filenames, err := convertInput.resolve(args)
+	if err != nil {
+		return err
+	}
+	if len(filenames) != 1 {
+		convertCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	name := convertInput.displayName(filenames[0])
+
+	doc, err := loadDocument(filenames[0], name, *convertCompat)
+	if err != nil {
+		return err
+	}
+	output, err := emit(doc)
+	if err != nil {
+		return fmt.Errorf("converting %q to %s: %s", name, *convertTo, err)
+	}
+
+	if *convertOutput != "" {
+		return ioutil.WriteFile(*convertOutput, output, 0644)
+	}
+	_, err = os.Stdout.Write(output)
+	return err
+}
+
+// readInput reads the (decompressed) content of the file, or of stdin
+func readInput(filename, name string) ([]byte, error) {
+	var content []byte
+	var err error
+	if filename == stdinArg {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decompress(content, name)
+}
+
+// writeInPlace replaces the content of the file, keeping its permissions.
+// It refuses compressed files, which are read decompressed.
+func writeInPlace(filename string, content []byte) error {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if detectCompression(bufio.NewReader(bytes.NewReader(raw)), filename) != uncompressed {
+		return fmt.Errorf("can't edit compressed files in place")
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, stat.Mode())
+}
+
+// loadDocument reads, fixes and parses the file. The fixer skips over
+// single quoted strings, which Parse accepts.
+func loadDocument(filename, name string, compat jsoncomma.Version) (*jsoncomma.Document, error) {
+	content, err := readInput(filename, name)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %s", name, err)
+	}
+	var fixed bytes.Buffer
+	config := &jsoncomma.Config{Version: compat, SingleQuotes: true}
+	if _, err := jsoncomma.Fix(config, bytes.NewReader(content), &fixed); err != nil {
+		return nil, fmt.Errorf("fixing %q: %s", name, err)
+	}
+	doc, err := jsoncomma.Parse(fixed.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %s", name, err)
+	}
+	return doc, nil
+}
+
+// pointerPath is the JSON Pointer of the child key of the value at path
+func pointerPath(path, key string) string {
+	return path + jsoncomma.FormatPointer(key)
+}
+
+// displayPointer is the pointer, in messages (the root is an empty pointer)
+func displayPointer(path string) string {
+	if path == "" {
+		return "the root"
+	}
+	return path
+}
+
+// jsonString encodes s as a JSON string, without escaping HTML
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// strings can always be encoded
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// emitJSON writes the document as strict JSON, indented with tabs. There
+// is no way to keep the comments.
+func emitJSON(doc *jsoncomma.Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, doc.Root, ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v *jsoncomma.Value, indent string) error {
+	switch v.Kind {
+	case jsoncomma.String:
+		s, err := v.Str()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(jsonString(s))
+
+	case jsoncomma.Object:
+		if len(v.Members) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, member := range v.Members {
+			buf.WriteString(indent + "\t" + jsonString(member.Key) + ": ")
+			if err := writeJSON(buf, member.Value, indent+"\t"); err != nil {
+				return err
+			}
+			if i < len(v.Members)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+
+	case jsoncomma.Array:
+		if len(v.Elements) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, element := range v.Elements {
+			buf.WriteString(indent + "\t")
+			if err := writeJSON(buf, element, indent+"\t"); err != nil {
+				return err
+			}
+			if i < len(v.Elements)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+
+	default:
+		// numbers are kept as written
+		buf.WriteString(v.Raw)
+	}
+	return nil
+}
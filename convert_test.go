@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestConvert(t *testing.T) {
+	in := "// top\n{\n\t\"name\": \"app\" // inline\n\t\"big\": 12345678901234567890.10\n\t\"db\": {\"port\": 5432}\n\t\"servers\": [{\"ip\": \"a\"} {\"ip\": \"b\"}]\n\t\"yes\": [1, [2]]\n}\n"
+
+	table := []struct {
+		format string
+		out    string
+	}{
+		{
+			format: "yaml",
+			out: `# top
+name: "app" # inline
+big: 12345678901234567890.10
+db:
+  port: 5432
+servers:
+  - ip: "a"
+  - ip: "b"
+"yes":
+  - 1
+  - - 2
+`,
+		},
+		{
+			format: "toml",
+			out: `# top
+name = "app" # inline
+big = 12345678901234567890.10
+yes = [1, [2]]
+
+[db]
+port = 5432
+
+[[servers]]
+ip = "a"
+
+[[servers]]
+ip = "b"
+`,
+		},
+		{
+			format: "json",
+			out: `{
+	"name": "app",
+	"big": 12345678901234567890.10,
+	"db": {
+		"port": 5432
+	},
+	"servers": [
+		{
+			"ip": "a"
+		},
+		{
+			"ip": "b"
+		}
+	],
+	"yes": [
+		1,
+		[
+			2
+		]
+	]
+}
+`,
+		},
+	}
+
+	for _, row := range table {
+		doc, err := jsoncomma.Parse([]byte(in))
+		if err != nil {
+			t.Fatalf("parsing: %s", err)
+		}
+		out, err := emitters[row.format](doc)
+		if err != nil {
+			t.Errorf("%s: %s", row.format, err)
+			continue
+		}
+		if string(out) != row.out {
+			t.Errorf("%s: expected\n%s\ngot\n%s", row.format, row.out, out)
+		}
+	}
+}
+
+func TestConvertTOMLErrors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{in: `[1, 2]`, err: "top-level array"},
+		{in: `{"a": {"b": null}}`, err: "/a/b is null"},
+		{in: `{"a": [1, null]}`, err: "/a/1 is null"},
+		{in: `{"n": 9223372036854775808}`, err: "doesn't fit"},
+	}
+	for _, row := range table {
+		doc, err := jsoncomma.Parse([]byte(row.in))
+		if err != nil {
+			t.Fatalf("parsing %s: %s", row.in, err)
+		}
+		_, err = emitTOML(doc)
+		if err == nil || !strings.Contains(err.Error(), row.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", row.in, row.err, err)
+		}
+	}
+}
+
+// the fixer skips over single quoted strings, so their commas and spaces
+// are kept (convert, diff and the lint rules all load documents this way)
+func TestLoadDocumentSingleQuotes(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"a.json":     "{'a': '1 2' 'b': [\"3 4\" 'x, y']}",
+			"rules.json": "{'rules': {'max-depth': {'severity': 'error' 'max': 3}}}",
+			"typo.json":  "{'rules': {'max depth': 'error'}}",
+		})
+
+		doc, err := loadDocument("a.json", "a.json", jsoncomma.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := emitJSON(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "{\n\t\"a\": \"1 2\",\n\t\"b\": [\n\t\t\"3 4\",\n\t\t\"x, y\"\n\t]\n}\n"
+		if string(out) != expected {
+			t.Errorf("actual:\n%s\nexpected:\n%s", out, expected)
+		}
+
+		config, err := loadLintConfig("rules.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := config.Rules[jsoncomma.RuleMaxDepth]; rule.Severity != jsoncomma.Error || rule.Max != 3 {
+			t.Errorf("unexpected rule: %+v", rule)
+		}
+		if _, err := loadLintConfig("typo.json"); err == nil || !strings.Contains(err.Error(), `"max depth"`) {
+			t.Errorf("expected the rule name to be kept, got %v", err)
+		}
+	})
+}
+
+// -compat selects the heuristics of the fixer, which runs before parsing:
+// v1 doesn't know block comments, and adds commas in them
+func TestLoadDocumentCompat(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"a.json": "{\n\t/* ports 80 443 */\n\t\"port\": 80\n}",
+		})
+		expected := map[jsoncomma.Version]string{
+			jsoncomma.Default: "# ports 80, 443\nport: 80\n",
+			jsoncomma.V2:      "# ports 80 443\nport: 80\n",
+		}
+		for version, out := range expected {
+			doc, err := loadDocument("a.json", "a.json", version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yaml, err := emitYAML(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(yaml) != out {
+				t.Errorf("%s: actual %q, expected %q", version, yaml, out)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var detectCmd = &command{
+	name:  "detect",
+	args:  "files...",
+	short: "Tells whether each file is JSON-like, based on its content only",
+	long:  "Exits with status 1 if at least one isn't.",
+	flags: flag.NewFlagSet("detect", flag.ExitOnError),
+	files: true,
+}
+
+var detectJSON = detectCmd.flags.Bool("json", false, "print a JSON object per file")
+var detectInput = addInputFlags(detectCmd.flags)
+
+func init() {
+	detectCmd.run = runDetect
+}
+
+func runDetect(args []string) error {
+	filenames, err := detectInput.resolve(args)
+	if err != nil {
+		return err
+	}
+	if len(filenames) == 0 {
+		detectCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+
+	failed := false
+	notJSONLike := false
+	for _, filename := range filenames {
+		name := detectInput.displayName(filename)
+
+		content, err := readInput(filename, name)
+		if err != nil {
+			log.Printf("detecting %q: %s", name, err)
+			failed = true
+			continue
+		}
+
+		detection := jsoncomma.Detect(content)
+		if !detection.JSONLike {
+			notJSONLike = true
+		}
+
+		if *detectJSON {
+			if err := encoder.Encode(kv{
+				"file":       name,
+				"jsonlike":   detection.JSONLike,
+				"confidence": detection.Confidence,
+				"reason":     detection.Reason,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		verdict := "json-like"
+		if !detection.JSONLike {
+			verdict = "not json-like"
+		}
+		fmt.Printf("%s: %s (confidence %.2f: %s)\n", name, verdict, detection.Confidence, detection.Reason)
+	}
+
+	if failed {
+		return exitStatus(2)
+	}
+	if notJSONLike {
+		return exitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunDetect(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"a.json":    "{\"a\": [1 2,]}",
+			"README.md": "# title\n\nsome *markdown*\n",
+		})
+
+		*detectJSON = true
+		defer func() {
+			*detectJSON = false
+		}()
+
+		var err error
+		out := withStdio(t, []byte("[1, 2, 3]"), func() {
+			err = runDetect([]string{"a.json", "-", "README.md"})
+		})
+		// one of them isn't JSON-like
+		if err != exitStatus(1) {
+			t.Errorf("expected the exit status 1, got %v", err)
+		}
+
+		expected := []struct {
+			file     string
+			jsonlike bool
+		}{
+			{"a.json", true},
+			{"<stdin>", true},
+			{"README.md", false},
+		}
+		decoder := json.NewDecoder(bytes.NewReader(out))
+		for _, e := range expected {
+			var line struct {
+				File       string   `json:"file"`
+				JSONLike   *bool    `json:"jsonlike"`
+				Confidence *float64 `json:"confidence"`
+				Reason     string   `json:"reason"`
+			}
+			if err := decoder.Decode(&line); err != nil {
+				t.Fatalf("%s: %s (output %q)", e.file, err, out)
+			}
+			if line.File != e.file || line.JSONLike == nil || *line.JSONLike != e.jsonlike || line.Confidence == nil || line.Reason == "" {
+				t.Errorf("%s: unexpected line %+v", e.file, line)
+			}
+		}
+		if decoder.More() {
+			t.Errorf("expected a line per file, got %q", out)
+		}
+
+		// all JSON-like, as text
+		*detectJSON = false
+		out = withStdio(t, nil, func() {
+			err = runDetect([]string{"a.json"})
+		})
+		if err != nil || !strings.HasPrefix(string(out), "a.json: json-like (confidence ") {
+			t.Errorf("expected a json-like line and no error, got %q (%v)", out, err)
+		}
+
+		// a file which can't be read
+		withStdio(t, nil, func() {
+			err = runDetect([]string{"a.json", "missing.json"})
+		})
+		if err != exitStatus(2) {
+			t.Errorf("expected the exit status 2, got %v", err)
+		}
+	})
+}
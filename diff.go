@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var diffCmd = &command{
+	name:  "diff",
+	args:  "a b",
+	short: "Compares two JSON-like files structurally",
+	long: "Commas, comments, whitespace and the way numbers and strings are written\n" +
+		"don't matter. Exits with status 1 if they are different, and 2 on errors\n" +
+		"(like diff).",
+	flags: flag.NewFlagSet("diff", flag.ExitOnError),
+	files: true,
+}
+
+var diffSet = diffCmd.flags.Bool("set", false, "compare arrays as sets: the order and the duplicates don't matter")
+var diffJSON = diffCmd.flags.Bool("json", false, "print the differences as a JSON array of {op, path, old, new}")
+var diffQuiet = diffCmd.flags.Bool("q", false, "don't print anything, only set the exit status")
+
+func init() {
+	diffCmd.run = runDiff
+}
+
+// diffChange is a difference at a JSON Pointer
+type diffChange struct {
+	// Op is add, remove or replace (like in JSON Patch)
+	Op   string          `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+func runDiff(args []string) error {
+	if len(args) != 2 {
+		diffCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	if args[0] == stdinArg && args[1] == stdinArg {
+		return fmt.Errorf("can't read from stdin (%q) more than once", stdinArg)
+	}
+
+	var docs [2]*jsoncomma.Document
+	for i, filename := range args {
+		name := filename
+		if filename == stdinArg {
+			name = "<stdin>"
+		}
+		doc, err := loadDocument(filename, name, jsoncomma.Default)
+		if err != nil {
+			// errors are the exit status 2
+			fmt.Fprintln(os.Stderr, err)
+			return exitStatus(2)
+		}
+		docs[i] = doc
+	}
+
+	d := &differ{set: *diffSet}
+	if err := d.diff("", docs[0].Root, docs[1].Root); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitStatus(2)
+	}
+
+	if !*diffQuiet {
+		if err := printDiff(d.changes); err != nil {
+			return err
+		}
+	}
+	if len(d.changes) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+func printDiff(changes []diffChange) error {
+	if *diffJSON {
+		if changes == nil {
+			changes = []diffChange{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(changes)
+	}
+
+	for _, change := range changes {
+		switch change.Op {
+		case "add":
+			fmt.Printf("+ %s: %s\n", displayPointer(change.Path), change.New)
+		case "remove":
+			fmt.Printf("- %s: %s\n", displayPointer(change.Path), change.Old)
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", displayPointer(change.Path), change.Old, change.New)
+		}
+	}
+	return nil
+}
+
+// differ collects the differences between two values
+type differ struct {
+	// set is true to compare arrays as sets
+	set     bool
+	changes []diffChange
+}
+
+func (d *differ) add(op, path string, old, new *jsoncomma.Value) error {
+	change := diffChange{Op: op, Path: path}
+	var err error
+	if old != nil {
+		if change.Old, err = compactJSON(old); err != nil {
+			return err
+		}
+	}
+	if new != nil {
+		if change.New, err = compactJSON(new); err != nil {
+			return err
+		}
+	}
+	d.changes = append(d.changes, change)
+	return nil
+}
+
+func (d *differ) diff(path string, a, b *jsoncomma.Value) error {
+	if a.Kind != b.Kind {
+		return d.add("replace", path, a, b)
+	}
+
+	switch a.Kind {
+	case jsoncomma.Object:
+		return d.diffObjects(path, a, b)
+	case jsoncomma.Array:
+		if d.set {
+			return d.diffSets(path, a, b)
+		}
+		return d.diffArrays(path, a, b)
+	}
+
+	equal, err := equalScalars(a, b)
+	if err != nil {
+		return err
+	}
+	if !equal {
+		return d.add("replace", path, a, b)
+	}
+	return nil
+}
+
+// keys returns the keys of the object in order, without the duplicates
+func keys(v *jsoncomma.Value) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, member := range v.Members {
+		if !seen[member.Key] {
+			seen[member.Key] = true
+			keys = append(keys, member.Key)
+		}
+	}
+	return keys
+}
+
+func (d *differ) diffObjects(path string, a, b *jsoncomma.Value) error {
+	for _, key := range keys(a) {
+		memberPath := pointerPath(path, key)
+		old := a.Lookup(key)
+		new := b.Lookup(key)
+		var err error
+		if new == nil {
+			err = d.add("remove", memberPath, old.Value, nil)
+		} else {
+			err = d.diff(memberPath, old.Value, new.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, key := range keys(b) {
+		if a.Lookup(key) == nil {
+			if err := d.add("add", pointerPath(path, key), nil, b.Lookup(key).Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffArrays compares the elements at the same index
+func (d *differ) diffArrays(path string, a, b *jsoncomma.Value) error {
+	for i := 0; i < len(a.Elements) || i < len(b.Elements); i++ {
+		elementPath := fmt.Sprintf("%s/%d", path, i)
+		var err error
+		switch {
+		case i >= len(b.Elements):
+			err = d.add("remove", elementPath, a.Elements[i], nil)
+		case i >= len(a.Elements):
+			err = d.add("add", elementPath, nil, b.Elements[i])
+		default:
+			err = d.diff(elementPath, a.Elements[i], b.Elements[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffSets reports the elements of a which aren't in b as removed (with
+// their index in a), and the ones of b which aren't in a as added (with
+// their index in b)
+func (d *differ) diffSets(path string, a, b *jsoncomma.Value) error {
+	inA := map[string]bool{}
+	inB := map[string]bool{}
+	canonicalA := make([]string, len(a.Elements))
+	canonicalB := make([]string, len(b.Elements))
+	var err error
+	for i, element := range a.Elements {
+		if canonicalA[i], err = canonical(element); err != nil {
+			return err
+		}
+		inA[canonicalA[i]] = true
+	}
+	for i, element := range b.Elements {
+		if canonicalB[i], err = canonical(element); err != nil {
+			return err
+		}
+		inB[canonicalB[i]] = true
+	}
+
+	for i, element := range a.Elements {
+		if !inB[canonicalA[i]] {
+			if err := d.add("remove", fmt.Sprintf("%s/%d", path, i), element, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for i, element := range b.Elements {
+		if !inA[canonicalB[i]] {
+			if err := d.add("add", fmt.Sprintf("%s/%d", path, i), nil, element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// equalScalars compares null, bools, numbers (by value) and strings (once
+// decoded)
+func equalScalars(a, b *jsoncomma.Value) (bool, error) {
+	switch a.Kind {
+	case jsoncomma.Number:
+		x, ok := new(big.Rat).SetString(a.Raw)
+		if !ok {
+			return false, fmt.Errorf("invalid number %s", a.Raw)
+		}
+		y, ok := new(big.Rat).SetString(b.Raw)
+		if !ok {
+			return false, fmt.Errorf("invalid number %s", b.Raw)
+		}
+		return x.Cmp(y) == 0, nil
+	case jsoncomma.String:
+		x, err := a.Str()
+		if err != nil {
+			return false, err
+		}
+		y, err := b.Str()
+		if err != nil {
+			return false, err
+		}
+		return x == y, nil
+	}
+	return a.Raw == b.Raw, nil
+}
+
+// canonical returns a string which is the same for values which are equal:
+// the keys are sorted, the numbers and the strings normalized, and the
+// arrays sorted too (in set mode, nested arrays are sets as well)
+func canonical(v *jsoncomma.Value) (string, error) {
+	switch v.Kind {
+	case jsoncomma.Number:
+		n, ok := new(big.Rat).SetString(v.Raw)
+		if !ok {
+			return "", fmt.Errorf("invalid number %s", v.Raw)
+		}
+		return n.RatString(), nil
+	case jsoncomma.String:
+		s, err := v.Str()
+		if err != nil {
+			return "", err
+		}
+		return jsonString(s), nil
+	case jsoncomma.Array:
+		elements := make([]string, len(v.Elements))
+		for i, element := range v.Elements {
+			c, err := canonical(element)
+			if err != nil {
+				return "", err
+			}
+			elements[i] = c
+		}
+		sort.Strings(elements)
+		return "[" + strings.Join(elements, ",") + "]", nil
+	case jsoncomma.Object:
+		var members []string
+		for _, key := range keys(v) {
+			c, err := canonical(v.Lookup(key).Value)
+			if err != nil {
+				return "", err
+			}
+			members = append(members, jsonString(key)+":"+c)
+		}
+		sort.Strings(members)
+		return "{" + strings.Join(members, ",") + "}", nil
+	}
+	return v.Raw, nil
+}
+
+// compactJSON returns the value as compact, strict JSON
+func compactJSON(v *jsoncomma.Value) ([]byte, error) {
+	var indented, compact bytes.Buffer
+	if err := writeJSON(&indented, v, ""); err != nil {
+		return nil, err
+	}
+	if err := json.Compact(&compact, indented.Bytes()); err != nil {
+		return nil, err
+	}
+	return compact.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestDiff(t *testing.T) {
+	table := []struct {
+		a, b    string
+		set     bool
+		changes []string
+	}{
+		{
+			a:       "{\"a\": 1.0 // one\n\"b\": \"\\u0078\"}",
+			b:       `{"b": "x", "a": 1}`,
+			changes: nil,
+		},
+		{
+			a:       `{"a": 1, "b": [1 2], "c": {"~/": 0}}`,
+			b:       `{"a": "1", "b": [1, 2, 3], "c": {}}`,
+			changes: []string{`replace /a 1 "1"`, `add /b/2  3`, `remove /c/~0~1 0 `},
+		},
+		{
+			a:       `[1, 2, 3]`,
+			b:       `[3, 2, 1]`,
+			changes: []string{`replace /0 1 3`, `replace /2 3 1`},
+		},
+		{
+			a:       `[1, 2, 3, {"a": [1, 2]}]`,
+			b:       `[{"a": [2, 1]}, 3, 2, 1e0]`,
+			set:     true,
+			changes: nil,
+		},
+		{
+			a:       `[1, 2]`,
+			b:       `[2, 3]`,
+			set:     true,
+			changes: []string{`remove /0 1 `, `add /1  3`},
+		},
+	}
+
+	for _, row := range table {
+		a, err := jsoncomma.Parse([]byte(row.a))
+		if err != nil {
+			t.Fatalf("parsing %s: %s", row.a, err)
+		}
+		b, err := jsoncomma.Parse([]byte(row.b))
+		if err != nil {
+			t.Fatalf("parsing %s: %s", row.b, err)
+		}
+
+		d := &differ{set: row.set}
+		if err := d.diff("", a.Root, b.Root); err != nil {
+			t.Errorf("%s vs %s: %s", row.a, row.b, err)
+			continue
+		}
+		var changes []string
+		for _, change := range d.changes {
+			changes = append(changes, change.Op+" "+change.Path+" "+string(change.Old)+" "+string(change.New))
+		}
+		if !reflect.DeepEqual(changes, row.changes) {
+			t.Errorf("%s vs %s: expected %q, got %q", row.a, row.b, row.changes, changes)
+		}
+	}
+}
+
+// the inputs are fixed and parsed: commas and comments don't make a
+// difference
+func TestRunDiffChurn(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"a.json": "{\"a\": [1 2 3], \"b\": {'c': 'x, y' \"d\": null,},}",
+			"b.json": "// reformatted\n{\n\t\"a\": [1, 2, 3], // numbers\n\t\"b\": {\"c\": \"x, y\", \"d\": null}\n}\n",
+			"c.json": "{\"a\": [1 2 3] \"b\": {\"c\": \"x y\" \"d\": null}}",
+		})
+
+		var err error
+		out := withStdio(t, nil, func() {
+			err = runDiff([]string{"a.json", "b.json"})
+		})
+		if err != nil || len(out) != 0 {
+			t.Errorf("expected no difference, got %q (%v)", out, err)
+		}
+
+		out = withStdio(t, nil, func() {
+			err = runDiff([]string{"a.json", "c.json"})
+		})
+		if err != exitStatus(1) || string(out) != "~ /b/c: \"x, y\" -> \"x y\"\n" {
+			t.Errorf("expected the string to differ, got %q (%v)", out, err)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var doctorCmd = &command{
+	name:  "doctor",
+	short: "Diagnoses the setup used by editor plugins (build, install, $PATH, server)",
+	long:  "Exits with status 1 if a check fails.",
+	flags: flag.NewFlagSet("doctor", flag.ExitOnError),
+}
+
+var doctorJSON = doctorCmd.flags.Bool("json", false, "print the report as JSON")
+
+func init() {
+	doctorCmd.run = runDoctor
+}
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the result of one of doctor's checks
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// doctorReport is everything doctor found out
+type doctorReport struct {
+	Build  map[string]string `json:"build"`
+	Checks []doctorCheck     `json:"checks"`
+	Passed bool              `json:"passed"`
+}
+
+func (r *doctorReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, doctorCheck{
+		Name:   name,
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	})
+	if status == checkFail {
+		r.Passed = false
+	}
+}
+
+func runDoctor(args []string) error {
+	if len(args) != 0 {
+		doctorCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	report := &doctorReport{
+		Build:  buildInfo(),
+		Passed: true,
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		report.add("executable", checkFail, "finding the running executable: %s", err)
+	} else {
+		report.add("executable", checkPass, "%s", exe)
+		doctorInstall(report, exe)
+		doctorPath(report, exe)
+		doctorServer(report, exe)
+	}
+
+	if *doctorJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(report)
+	}
+
+	if !report.Passed {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+func buildInfo() map[string]string {
+	info := map[string]string{
+		"version": version,
+		"commit":  commit,
+		"date":    date,
+		"go":      runtime.Version(),
+		"os/arch": runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = build.Main.Path
+		info["module version"] = build.Main.Version
+		for _, dep := range build.Deps {
+			info["dep "+dep.Path] = dep.Version
+		}
+	}
+	return info
+}
+
+// sameFile returns true if both paths are the same file
+func sameFile(a, b string) bool {
+	statA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	statB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(statA, statB)
+}
+
+func doctorInstall(report *doctorReport, exe string) {
+	path, err := installPath()
+	if err != nil {
+		report.add("install", checkFail, "%s", err)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		report.add("install", checkWarn, "not installed in %s (run $ jsoncomma install)", path)
+		return
+	}
+	if sameFile(path, exe) {
+		report.add("install", checkPass, "running from %s", path)
+		return
+	}
+	installed, err := installedVersion(path)
+	if err != nil {
+		report.add("install", checkFail, "%s is installed but doesn't run: %s", path, err)
+		return
+	}
+	if installed != versionLine() {
+		report.add("install", checkWarn, "%s is a different version (%s), run $ jsoncomma install to update it", path, installed)
+		return
+	}
+	report.add("install", checkPass, "%s is the same version", path)
+}
+
+func doctorPath(report *doctorReport, exe string) {
+	found, err := exec.LookPath("jsoncomma")
+	if err != nil {
+		report.add("$PATH", checkWarn, "jsoncomma isn't in $PATH (plugins should use the install path anyway)")
+		return
+	}
+	found, err = filepath.Abs(found)
+	if err != nil {
+		report.add("$PATH", checkWarn, "%s", err)
+		return
+	}
+	if !sameFile(found, exe) {
+		report.add("$PATH", checkWarn, "jsoncomma in $PATH is %s, not this executable", found)
+		return
+	}
+	report.add("$PATH", checkPass, "jsoncomma in $PATH is this executable")
+}
+
+// doctorServer starts a server (like plugins do), fixes a payload and shuts
+// it down
+func doctorServer(report *doctorReport, exe string) {
+	cmd := exec.Command(exe, "server", "-port", "0")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		report.add("server start", checkFail, "%s", err)
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		report.add("server start", checkFail, "%s", err)
+		return
+	}
+
+	exited := make(chan error, 1)
+	lines := make(chan string, 2)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+		exited <- cmd.Wait()
+	}()
+
+	kill := func() {
+		cmd.Process.Kill()
+	}
+
+	var started struct {
+		Kind string `json:"kind"`
+		Addr string `json:"addr"`
+	}
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			// stderr is only safe to read once the process is done
+			<-exited
+			report.add("server start", checkFail, "exited without printing anything (stderr: %q)", stderr.String())
+			return
+		}
+		if err := json.Unmarshal([]byte(line), &started); err != nil || started.Kind != "started" {
+			report.add("server start", checkFail, "unexpected first line: %q", line)
+			kill()
+			return
+		}
+	case <-time.After(5 * time.Second):
+		report.add("server start", checkFail, "nothing printed after 5 seconds")
+		kill()
+		return
+	}
+	report.add("server start", checkPass, "listening on %s", started.Addr)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := "http://" + started.Addr
+
+	payload := "{\n\t\"hello\": \"world\"\n\t\"list\": [1 2 3,],\n}\n"
+	var expected bytes.Buffer
+	if _, err := jsoncomma.Fix(&jsoncomma.Config{}, strings.NewReader(payload), &expected); err != nil {
+		report.add("server fix", checkFail, "fixing locally: %s", err)
+	} else if resp, err := client.Post(url+"/", "text/plain; charset=utf-8", strings.NewReader(payload)); err != nil {
+		report.add("server fix", checkFail, "%s", err)
+	} else {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			report.add("server fix", checkFail, "reading the response: %s", err)
+		} else if resp.StatusCode != http.StatusOK {
+			report.add("server fix", checkFail, "status %d: %s", resp.StatusCode, body)
+		} else if !bytes.Equal(body, expected.Bytes()) {
+			report.add("server fix", checkFail, "got %q, expected %q", body, expected.String())
+		} else {
+			report.add("server fix", checkPass, "sample payload round-tripped")
+		}
+	}
+
+	resp, err := client.Get(url + "/shutdown")
+	if err != nil {
+		report.add("server shutdown", checkFail, "%s", err)
+		kill()
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var shutdown struct {
+		Timedout *bool `json:"timedout"`
+	}
+	if err := json.Unmarshal(body, &shutdown); err != nil || shutdown.Timedout == nil {
+		report.add("server shutdown", checkFail, "unexpected response: %q", body)
+		kill()
+		return
+	}
+	if *shutdown.Timedout {
+		report.add("server shutdown", checkWarn, "handlers timed out")
+	}
+
+	select {
+	case <-exited:
+		report.add("server shutdown", checkPass, "stopped after /shutdown")
+	case <-time.After(5 * time.Second):
+		report.add("server shutdown", checkFail, "still running 5 seconds after /shutdown")
+		kill()
+	}
+}
+
+func printDoctorReport(report *doctorReport) {
+	fmt.Println("Build:")
+	keys := make([]string, 0, len(report.Build))
+	for key := range report.Build {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %-16s %s\n", key, report.Build[key])
+	}
+
+	fmt.Println()
+	fmt.Println("Checks:")
+	for _, check := range report.Checks {
+		fmt.Printf("  [%s] %-16s %s\n", check.Status, check.Name, check.Detail)
+	}
+
+	fmt.Println()
+	if report.Passed {
+		fmt.Println("Everything looks good")
+	} else {
+		fmt.Println("Some checks failed. Please include this report when raising an issue.")
+	}
+}
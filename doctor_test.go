@@ -0,0 +1,211 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// withEnv sets the environment variable while f runs
+func withEnv(key, value string, f func()) {
+	old, set := os.LookupEnv(key)
+	os.Setenv(key, value)
+	defer func() {
+		if set {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}()
+	f()
+}
+
+// checks returns the checks of the report as "name status"
+func checks(report *doctorReport) []string {
+	var checks []string
+	for _, check := range report.Checks {
+		checks = append(checks, check.Name+" "+check.Status)
+	}
+	return checks
+}
+
+func TestDoctorInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma-doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	withEnv("XDG_CONFIG_HOME", dir, func() {
+		path, err := installPath()
+		if err != nil || !strings.HasPrefix(path, dir) {
+			t.Skip("the install path can't be moved with XDG_CONFIG_HOME on this system")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		exe := filepath.Join(dir, "exe")
+		fakeExecutable(t, exe, versionLine())
+
+		rows := []struct {
+			// install writes what is at the install path
+			install func()
+			// exe is the running executable
+			exe    string
+			status string
+			detail string
+		}{
+			{func() {}, exe, checkWarn, "not installed"},
+			{func() { fakeExecutable(t, path, "v0.0.1 abc 2020-01-01") }, exe, checkWarn, "different version"},
+			{func() { ioutil.WriteFile(path, []byte("not a program"), 0644) }, exe, checkFail, "doesn't run"},
+			{func() { fakeExecutable(t, path, versionLine()) }, exe, checkPass, "same version"},
+			// running from the install path: it isn't run again
+			{func() { fakeExecutable(t, path, "v0.0.1 abc 2020-01-01") }, path, checkPass, "running from"},
+		}
+		for i, row := range rows {
+			os.Remove(path)
+			row.install()
+
+			report := &doctorReport{Passed: true}
+			doctorInstall(report, row.exe)
+			if len(report.Checks) != 1 || report.Checks[0].Status != row.status || !strings.Contains(report.Checks[0].Detail, row.detail) {
+				t.Errorf("%d: expected %s (%s), got %+v", i, row.status, row.detail, report.Checks)
+			}
+			if report.Passed != (row.status != checkFail) {
+				t.Errorf("%d: expected passed to be %t", i, row.status != checkFail)
+			}
+		}
+	})
+}
+
+func TestDoctorPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma-doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "exe")
+	fakeExecutable(t, exe, versionLine())
+	inPath := filepath.Join(bin, "jsoncomma")
+
+	rows := []struct {
+		// setup writes what is in $PATH
+		setup  func()
+		status string
+		detail string
+	}{
+		{func() {}, checkWarn, "isn't in $PATH"},
+		{func() { os.Symlink(exe, inPath) }, checkPass, "is this executable"},
+		// another one shadows it
+		{func() { fakeExecutable(t, inPath, versionLine()) }, checkWarn, "not this executable"},
+	}
+	withEnv("PATH", bin, func() {
+		for i, row := range rows {
+			os.Remove(inPath)
+			row.setup()
+
+			report := &doctorReport{Passed: true}
+			doctorPath(report, exe)
+			if len(report.Checks) != 1 || report.Checks[0].Status != row.status || !strings.Contains(report.Checks[0].Detail, row.detail) {
+				t.Errorf("%d: expected %s (%s), got %+v", i, row.status, row.detail, report.Checks)
+			}
+		}
+	})
+}
+
+func TestDoctorServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma-doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a fake server, which the fake executable says it listens on
+	fixes := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/shutdown" {
+			w.Write([]byte(`{"timedout": false}`))
+			return
+		}
+		if !fixes {
+			ioutil.ReadAll(r.Body)
+			w.Write([]byte("[]"))
+			return
+		}
+		jsoncomma.Fix(&jsoncomma.Config{}, r.Body, w)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	// a socket nothing listens on anymore
+	stale := httptest.NewServer(http.NotFoundHandler())
+	staleAddr := strings.TrimPrefix(stale.URL, "http://")
+	stale.Close()
+
+	started := func(addr string) string {
+		return `echo '{"kind": "started", "addr": "` + addr + `"}'`
+	}
+	rows := []struct {
+		script string
+		fixes  bool
+		checks []string
+	}{
+		{
+			script: started(addr),
+			fixes:  true,
+			checks: []string{"server start pass", "server fix pass", "server shutdown pass"},
+		},
+		{
+			script: started(addr),
+			fixes:  false,
+			checks: []string{"server start pass", "server fix fail", "server shutdown pass"},
+		},
+		{
+			script: started(staleAddr),
+			checks: []string{"server start pass", "server fix fail", "server shutdown fail"},
+		},
+		{
+			script: "echo 'listening'",
+			checks: []string{"server start fail"},
+		},
+		{
+			script: "echo 'no port' >&2\nexit 1",
+			checks: []string{"server start fail"},
+		},
+	}
+	for i, row := range rows {
+		exe := filepath.Join(dir, "exe")
+		if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\n"+row.script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		fixes = row.fixes
+
+		report := &doctorReport{Passed: true}
+		doctorServer(report, exe)
+		if actual := checks(report); !reflect.DeepEqual(actual, row.checks) {
+			t.Errorf("%d: expected %q, got %q (%+v)", i, row.checks, actual, report.Checks)
+		}
+	}
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var fixCmd = &command{
+	name:  "fix",
+	args:  "files...",
+	short: "Fixes the files in place (stdin, given as - or piped, goes to stdout)",
+	long:  "Gzip'd files are compressed back, bzip2'd files can only go to stdout.",
+	flags: flag.NewFlagSet("fix", flag.ExitOnError),
+	files: true,
+}
+
+var fixToStdout = fixCmd.flags.Bool("stdout", false, "write to stdout instead of in place")
+var fixOutput = fixCmd.flags.String("o", "", "write the fixed content of the (single) input to this `file` instead of in place")
+var fixOutdir = fixCmd.flags.String("outdir", "", "write the fixed files in this `dir` instead of in place, mirroring\nthe inputs' directory structure (relative to the current directory)")
+var fixPrintVersion = fixCmd.flags.Bool("version", false, "print the version and exits")
+var fixInput = addInputFlags(fixCmd.flags)
+var fixPatch = fixCmd.flags.Bool("p", false, "review each change interactively (answers are read from stdin), and only\nwrite the accepted ones, like git add -p")
+var fixVerbose = fixCmd.flags.Bool("v", false, "print what happened to each file, and a summary (on stderr)")
+var fixNoCache = fixCmd.flags.Bool("no-cache", false, "don't skip the files known to be fixed from previous runs\n(see jsoncomma cache)")
+var fixCompat = addCompatFlag(fixCmd.flags)
+
+var checkCmd = &command{
+	name:  "check",
+	args:  "files...",
+	short: "Lists the files which aren't fixed, without modifying them",
+	long:  "Exits with status 1 if there is at least one.",
+	flags: flag.NewFlagSet("check", flag.ExitOnError),
+	files: true,
+}
+
+var checkInput = addInputFlags(checkCmd.flags)
+var checkVerbose = checkCmd.flags.Bool("v", false, "print what would happen to each file, and a summary (on stderr)")
+var checkNoCache = checkCmd.flags.Bool("no-cache", false, "don't skip the files known to be fixed from previous runs\n(see jsoncomma cache)")
+var checkCompat = addCompatFlag(checkCmd.flags)
+
+func init() {
+	fixCmd.run = runFix
+	checkCmd.run = runCheck
+}
+
+// stdinArg is the file name which means "read from stdin"
+const stdinArg = "-"
+
+// inputFlags are the flags shared by the commands which read files or stdin
+type inputFlags struct {
+	stdinFilename *string
+}
+
+func addInputFlags(flags *flag.FlagSet) *inputFlags {
+	return &inputFlags{
+		stdinFilename: flags.String("stdin-filename", "", "act as if the content read from stdin came from this `path`\n(used in messages, and to detect the file type)"),
+	}
+}
+
+// compatFlag is a jsoncomma.Version, set from strings like v1
+type compatFlag jsoncomma.Version
+
+func (c *compatFlag) String() string {
+	return jsoncomma.Version(*c).String()
+}
+
+func (c *compatFlag) Set(s string) error {
+	version, err := jsoncomma.ParseVersion(s)
+	if err != nil {
+		return err
+	}
+	*c = compatFlag(version)
+	return nil
+}
+
+// addCompatFlag adds the -compat flag, which selects the version of the
+// heuristics (so that upgrading jsoncomma doesn't change the output)
+func addCompatFlag(flags *flag.FlagSet) *jsoncomma.Version {
+	var version jsoncomma.Version
+	flags.Var((*compatFlag)(&version), "compat", "place the commas like this `version` of the heuristics does (v1, v2, or latest for\nthe most recent). Without it, it's v1, so that upgrading never changes the output")
+	return &version
+}
+
+// stdinName is the name used to talk about stdin's content
+func (in *inputFlags) stdinName() string {
+	if *in.stdinFilename != "" {
+		return *in.stdinFilename
+	}
+	return "<stdin>"
+}
+
+// displayName is the name used in messages about the file
+func (in *inputFlags) displayName(filename string) string {
+	if filename == stdinArg {
+		return in.stdinName()
+	}
+	return filename
+}
+
+// resolve returns the files to process. If none are given and stdin is
+// piped, it reads from stdin. If none are given and stdin is a terminal,
+// it returns no file.
+func (in *inputFlags) resolve(args []string) ([]string, error) {
+	if len(args) == 0 {
+		piped, err := stdinIsPiped()
+		if err != nil {
+			return nil, err
+		}
+		if !piped {
+			return nil, nil
+		}
+		return []string{stdinArg}, nil
+	}
+
+	stdinCount := 0
+	for _, arg := range args {
+		if arg == stdinArg {
+			stdinCount++
+		}
+	}
+	if stdinCount > 1 {
+		return nil, fmt.Errorf("can't read from stdin (%q) more than once", stdinArg)
+	}
+	return args, nil
+}
+
+func runFix(args []string) error {
+	if *fixPrintVersion {
+		printVersion()
+		return nil
+	}
+
+	filenames, err := fixInput.resolve(args)
+	if err != nil {
+		return err
+	}
+	if len(filenames) == 0 {
+		usage()
+		return nil
+	}
+
+	if *fixPatch {
+		for _, filename := range filenames {
+			if filename == stdinArg {
+				return fmt.Errorf("-p reads the answers from stdin, so it can't fix stdin")
+			}
+		}
+		if *fixToStdout || *fixOutput != "" || *fixOutdir != "" {
+			return fmt.Errorf("-p only fixes files in place")
+		}
+		return runPatch(&jsoncomma.Config{Version: *fixCompat}, filenames, os.Stdin, os.Stdout)
+	}
+
+	opts := fixOptions{
+		tostdout: *fixToStdout,
+		output:   *fixOutput,
+		outdir:   *fixOutdir,
+		noCache:  *fixNoCache,
+		version:  *fixCompat,
+	}
+	if err := opts.validate(filenames); err != nil {
+		return err
+	}
+
+	stats := newRunStats(*fixVerbose, os.Stderr)
+	defer stats.report()
+
+	return fix(filenames, opts, stats)
+}
+
+func runCheck(args []string) error {
+	config := &jsoncomma.Config{Version: *checkCompat}
+
+	filenames, err := checkInput.resolve(args)
+	if err != nil {
+		return err
+	}
+	if len(filenames) == 0 {
+		checkCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	stats := newRunStats(*checkVerbose, os.Stderr)
+	defer stats.report()
+
+	var cache *fixedCache
+	if !*checkNoCache {
+		cache = openCache(config)
+	}
+
+	failed := false
+	unfixed := false
+	for _, filename := range filenames {
+		name := checkInput.displayName(filename)
+
+		var content []byte
+		var err error
+		if filename == stdinArg {
+			content, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			content, err = ioutil.ReadFile(filename)
+		}
+		if err != nil {
+			log.Print(err)
+			stats.add(name, jsoncomma.Result{}, err)
+			failed = true
+			continue
+		}
+		if filename != stdinArg && cache.isFixed(content) {
+			stats.addCached(name, int64(len(content)))
+			continue
+		}
+		raw := content
+		content, err = decompress(content, name)
+		if err != nil {
+			log.Printf("checking %q: %s", name, err)
+			stats.add(name, jsoncomma.Result{}, err)
+			failed = true
+			continue
+		}
+		result, fixed, err := isFixed(config, content)
+		stats.add(name, result, err)
+		if err != nil {
+			log.Printf("checking %q: %s", name, err)
+			failed = true
+			continue
+		}
+		if fixed && filename != stdinArg {
+			cache.markFixed(raw)
+		}
+		if !fixed {
+			fmt.Println(name)
+			unfixed = true
+		}
+	}
+
+	if failed {
+		return exitStatus(2)
+	}
+	if unfixed {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// isFixed returns true if fixing content wouldn't change anything
+func isFixed(config *jsoncomma.Config, content []byte) (jsoncomma.Result, bool, error) {
+	var out bytes.Buffer
+	out.Grow(len(content))
+	result, err := jsoncomma.Fix(config, bytes.NewReader(content), &out)
+	if err != nil {
+		return result, false, err
+	}
+	return result, bytes.Equal(content, out.Bytes()), nil
+}
+
+// stdinIsPiped returns true if stdin isn't a terminal
+func stdinIsPiped() (bool, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false, fmt.Errorf("getting os.stdin stat: %s", err)
+	}
+	return stat.Mode()&os.ModeCharDevice == 0, nil
+}
+
+// fixOptions describes where fix writes the fixed content
+type fixOptions struct {
+	tostdout bool
+	// output is the file to write the only input to
+	output string
+	// outdir is the directory where the inputs are mirrored
+	outdir string
+	// noCache disables the cache of the files known to be fixed (only used
+	// when fixing in place)
+	noCache bool
+	// version is the version of the heuristics
+	version jsoncomma.Version
+}
+
+func (opts fixOptions) validate(filenames []string) error {
+	set := 0
+	for _, b := range []bool{opts.tostdout, opts.output != "", opts.outdir != ""} {
+		if b {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("-stdout, -o and -outdir are mutually exclusive")
+	}
+	if opts.output != "" && len(filenames) != 1 {
+		return fmt.Errorf("-o requires exactly one input (got %d), use -outdir for several", len(filenames))
+	}
+	return nil
+}
+
+// destination returns the file the fixed content of filename should be
+// written to. It returns "" if it should be fixed in place, and stdinArg
+// if it should be written to stdout.
+func (opts fixOptions) destination(filename string) (string, error) {
+	if opts.tostdout {
+		return stdinArg, nil
+	}
+	if opts.output != "" {
+		return opts.output, nil
+	}
+	if opts.outdir != "" {
+		if filename == stdinArg {
+			if *fixInput.stdinFilename == "" {
+				return "", fmt.Errorf("-outdir requires -stdin-filename to know where to write stdin's content")
+			}
+			filename = *fixInput.stdinFilename
+		}
+		rel, err := relativeToWorkingDir(filename)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(opts.outdir, rel), nil
+	}
+	if filename == stdinArg {
+		// stdin can't be fixed in place, it always goes to stdout
+		return stdinArg, nil
+	}
+	return "", nil
+}
+
+// relativeToWorkingDir returns filename relative to the working directory.
+// It fails if filename isn't in the working directory (because it couldn't
+// be mirrored)
+func relativeToWorkingDir(filename string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q isn't in the current directory, so it can't be mirrored in -outdir", filename)
+	}
+	return rel, nil
+}
+
+func fix(filenames []string, opts fixOptions, stats *runStats) error {
+	var wg sync.WaitGroup
+
+	config := &jsoncomma.Config{Version: opts.version}
+
+	var cache *fixedCache
+	if !opts.noCache {
+		cache = openCache(config)
+	}
+
+	// the files we are reading from, which we must never overwrite
+	inputs := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		if filename != stdinArg {
+			if abs, err := filepath.Abs(filename); err == nil {
+				inputs[abs] = true
+			}
+		}
+	}
+
+	for _, filename := range filenames {
+		// I'm not sure about os.O_SYNC. I'm guessing I have to use
+		// it because
+
+		name := fixInput.displayName(filename)
+
+		dest, err := opts.destination(filename)
+		if err != nil {
+			log.Print(err)
+			stats.add(name, jsoncomma.Result{}, err)
+			continue
+		}
+
+		if dest == stdinArg {
+			in, err := openInput(filename)
+			if err != nil {
+				log.Print(err)
+				stats.add(name, jsoncomma.Result{}, err)
+				continue
+			}
+			result, err := fixStream(config, name, in, os.Stdout, false)
+			if err != nil {
+				log.Printf("fixing %q: %s", name, err)
+			}
+			stats.add(name, result, err)
+			in.Close()
+		} else if dest == "" {
+			wg.Add(1)
+			go func(config *jsoncomma.Config, filename string) {
+				defer wg.Done()
+				result, cached, err := fixfile(config, cache, filename)
+				if err != nil {
+					log.Println(err)
+				}
+				if cached {
+					stats.addCached(filename, result.Read)
+				} else {
+					stats.add(filename, result, err)
+				}
+			}(config, filename)
+		} else {
+			if abs, err := filepath.Abs(dest); err != nil || inputs[abs] {
+				err := fmt.Errorf("fixing %q: refusing to overwrite input %q", name, dest)
+				log.Print(err)
+				stats.add(name, jsoncomma.Result{}, err)
+				continue
+			}
+			if filename == stdinArg {
+				// stdin can only be read from one goroutine
+				result, err := fixto(config, filename, dest)
+				if err != nil {
+					log.Println(err)
+				}
+				stats.add(name, result, err)
+				continue
+			}
+			wg.Add(1)
+			go func(config *jsoncomma.Config, filename, dest string) {
+				defer wg.Done()
+				result, err := fixto(config, filename, dest)
+				if err != nil {
+					log.Println(err)
+				}
+				stats.add(filename, result, err)
+			}(config, filename, dest)
+		}
+
+	}
+	wg.Wait()
+	return nil
+}
+
+// openInput opens the file, or stdin if filename is stdinArg
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == stdinArg {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// fixto writes the fixed content of filename to dest, creating the parent
+// directories if needed. dest has the same permissions as filename.
+func fixto(config *jsoncomma.Config, filename, dest string) (jsoncomma.Result, error) {
+	name := fixInput.displayName(filename)
+
+	var perm os.FileMode = 0644
+	if filename != stdinArg {
+		stat, err := os.Stat(filename)
+		if err != nil {
+			return jsoncomma.Result{}, err
+		}
+		if stat.IsDir() {
+			return jsoncomma.Result{}, fmt.Errorf("fixing %q: is a directory", name)
+		}
+		perm = stat.Mode().Perm()
+
+		if destStat, err := os.Stat(dest); err == nil && os.SameFile(stat, destStat) {
+			return jsoncomma.Result{}, fmt.Errorf("fixing %q: refusing to overwrite the input", name)
+		}
+	}
+
+	in, err := openInput(filename)
+	if err != nil {
+		return jsoncomma.Result{}, err
+	}
+	defer in.Close()
+
+	// make sure we can compress it back before creating anything
+	bufin := bufio.NewReader(in)
+	if compression := detectCompression(bufin, name); compression == bzipped {
+		return jsoncomma.Result{}, fmt.Errorf("fixing %q: %s files can only be read, use -stdout", name, compression)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return jsoncomma.Result{}, err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return jsoncomma.Result{}, err
+	}
+
+	result, err := fixStream(config, name, bufin, out, true)
+	if err != nil {
+		out.Close()
+		return result, fmt.Errorf("fixing %q: %s", name, err)
+	}
+	return result, out.Close()
+}
+
+// fixfile fixes the file in place. It returns true if the file was skipped
+// because the cache knows it's fixed
+func fixfile(config *jsoncomma.Config, cache *fixedCache, filename string) (jsoncomma.Result, bool, error) {
+	// because we would be reading at the same time as reading
+	// from the same file, that means that the read operation and
+	// write operation are dependent, which doesn't work with Fixer
+	// (it assumes that they are two completely different things)
+
+	// so right now, I'll just do this big fat discusting thing
+	// FIXME: is there a nice way to kind of "split" the file,
+	// so they have two different carets? (maybe open the file twice?
+	// is that possible?)
+
+	// maybe it would be more efficient to write to another file
+	// and then delete the original file and rename <other> to <original>
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return jsoncomma.Result{}, false, err
+	}
+
+	if cache.isFixed(content) {
+		return jsoncomma.Result{Read: int64(len(content)), Written: int64(len(content))}, true, nil
+	}
+
+	// fix everything before truncating the file, so that we don't lose
+	// anything if it fails (for example, if it can't be compressed back)
+	var fixed bytes.Buffer
+	fixed.Grow(len(content))
+	result, err := fixStream(config, filename, bytes.NewReader(content), &fixed, true)
+	if err != nil {
+		return result, false, fmt.Errorf("fixing %q: %s", filename, err)
+	}
+	if !result.Changed() {
+		// don't touch the file if there is nothing to do
+		cache.markFixed(content)
+		return result, false, nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return result, false, err
+	}
+	defer f.Close()
+
+	if _, err := fixed.WriteTo(f); err != nil {
+		return result, false, fmt.Errorf("writing %q: %s", filename, err)
+	}
+	return result, false, nil
+}
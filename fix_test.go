@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// withStdio runs f with stdin reading the given content, and returns what f
+// wrote to stdout
+func withStdio(t *testing.T, stdin []byte, f func()) []byte {
+	in, err := ioutil.TempFile("", "jsoncomma-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(in.Name())
+	defer in.Close()
+	if _, err := in.Write(stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.TempFile("", "jsoncomma-stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = in, out
+	defer func() {
+		os.Stdin, os.Stdout = oldStdin, oldStdout
+	}()
+	f()
+
+	content, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// withStdinFilename sets -stdin-filename for the fix command while f runs
+func withStdinFilename(name string, f func()) {
+	old := *fixInput.stdinFilename
+	*fixInput.stdinFilename = name
+	defer func() {
+		*fixInput.stdinFilename = old
+	}()
+	f()
+}
+
+func TestResolveInputs(t *testing.T) {
+	rows := []struct {
+		args  []string
+		files []string
+		err   bool
+	}{
+		{args: []string{"a.json"}, files: []string{"a.json"}},
+		{args: []string{"-"}, files: []string{"-"}},
+		{args: []string{"a.json", "-", "b.json"}, files: []string{"a.json", "-", "b.json"}},
+		{args: []string{"-", "a.json", "-"}, err: true},
+	}
+	for _, row := range rows {
+		files, err := fixInput.resolve(row.args)
+		if (err != nil) != row.err || !reflect.DeepEqual(files, row.files) {
+			t.Errorf("%q: actual %q (error %v), expected %q (error %t)", row.args, files, err, row.files, row.err)
+		}
+	}
+
+	// - mixed with flags, in any order
+	for _, args := range [][]string{{"-stdout", "-stdin-filename", "a.json", "-"}, {"-", "-stdout", "-stdin-filename", "a.json"}} {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		stdout := flags.Bool("stdout", false, "")
+		input := addInputFlags(flags)
+		positional, err := parseInterspersed(flags, args)
+		if err != nil || !reflect.DeepEqual(positional, []string{"-"}) || !*stdout || input.displayName("-") != "a.json" {
+			t.Errorf("%q: expected -, -stdout and -stdin-filename, got %q, %t and %q (%v)", args, positional, *stdout, input.displayName("-"), err)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	rows := []struct {
+		stdinFilename string
+		filename      string
+		name          string
+	}{
+		{"", "a.json", "a.json"},
+		{"", "-", "<stdin>"},
+		{"conf/a.json", "-", "conf/a.json"},
+		{"conf/a.json", "b.json", "b.json"},
+	}
+	for _, row := range rows {
+		withStdinFilename(row.stdinFilename, func() {
+			if name := fixInput.displayName(row.filename); name != row.name {
+				t.Errorf("-stdin-filename %q, %q: actual %q, expected %q", row.stdinFilename, row.filename, name, row.name)
+			}
+		})
+	}
+}
+
+func TestFixStdin(t *testing.T) {
+	rows := []struct {
+		stdinFilename string
+		in            string
+		out           string
+	}{
+		{"", "[1 2,]", "[1, 2]"},
+		// stdin always goes to stdout, whatever its name
+		{"a.json", "{\"a\": 1 \"b\": 2}", "{\"a\": 1, \"b\": 2}"},
+	}
+	for _, row := range rows {
+		withStdinFilename(row.stdinFilename, func() {
+			stats := newRunStats(false, ioutil.Discard)
+			out := withStdio(t, []byte(row.in), func() {
+				fix([]string{stdinArg}, fixOptions{noCache: true}, stats)
+			})
+			if string(out) != row.out {
+				t.Errorf("%q: actual %q, expected %q", row.in, out, row.out)
+			}
+			if stats.failed != 0 {
+				t.Errorf("%q: expected no failure", row.in)
+			}
+		})
+	}
+}
+
+// inTempDir runs f in a new temporary directory
+func inTempDir(t *testing.T, f func(dir string)) {
+	dir, err := ioutil.TempDir("", "jsoncomma-fix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	f(dir)
+}
+
+func writeFiles(t *testing.T, files map[string]string) {
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectFiles(t *testing.T, files map[string]string) {
+	for name, expected := range files {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if string(content) != expected {
+			t.Errorf("%s: actual %q, expected %q", name, content, expected)
+		}
+	}
+}
+
+func TestFixOptionsValidate(t *testing.T) {
+	rows := []struct {
+		opts  fixOptions
+		files int
+		err   bool
+	}{
+		{fixOptions{}, 2, false},
+		{fixOptions{output: "out.json"}, 1, false},
+		{fixOptions{output: "out.json"}, 2, true},
+		{fixOptions{outdir: "out"}, 2, false},
+		{fixOptions{tostdout: true, output: "out.json"}, 1, true},
+		{fixOptions{output: "out.json", outdir: "out"}, 1, true},
+		{fixOptions{tostdout: true, outdir: "out"}, 1, true},
+	}
+	for _, row := range rows {
+		if err := row.opts.validate(make([]string, row.files)); (err != nil) != row.err {
+			t.Errorf("%+v with %d files: expected an error: %t, got %v", row.opts, row.files, row.err, err)
+		}
+	}
+}
+
+func TestFixOutdir(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		writeFiles(t, map[string]string{
+			"a.json":        "[1 2]",
+			"conf/b.json":   "{\"b\": 1,}",
+			"conf/c/d.json": "[]",
+		})
+
+		stats := newRunStats(false, ioutil.Discard)
+		fix([]string{"a.json", "conf/b.json", filepath.Join(dir, "conf/c/d.json")}, fixOptions{outdir: "out", noCache: true}, stats)
+		if stats.failed != 0 {
+			t.Errorf("expected no failure, got %d", stats.failed)
+		}
+		expectFiles(t, map[string]string{
+			// the inputs aren't touched
+			"a.json":      "[1 2]",
+			"conf/b.json": "{\"b\": 1,}",
+			// the directory structure is mirrored, absolute paths included
+			"out/a.json":        "[1, 2]",
+			"out/conf/b.json":   "{\"b\": 1}",
+			"out/conf/c/d.json": "[]",
+		})
+		if stat, err := os.Stat("out/conf/b.json"); err != nil || stat.Mode().Perm() != 0600 {
+			t.Errorf("expected the permissions of the input to be kept, got %v (%v)", stat.Mode(), err)
+		}
+
+		// stdin is mirrored as -stdin-filename
+		withStdinFilename("conf/e.json", func() {
+			withStdio(t, []byte("[3 4]"), func() {
+				fix([]string{stdinArg}, fixOptions{outdir: "out", noCache: true}, stats)
+			})
+		})
+		expectFiles(t, map[string]string{"out/conf/e.json": "[3, 4]"})
+		if _, err := (fixOptions{outdir: "out"}).destination(stdinArg); err == nil {
+			t.Errorf("expected an error without -stdin-filename")
+		}
+
+		// files outside of the working directory can't be mirrored
+		if _, err := (fixOptions{outdir: "out"}).destination(filepath.Join(dir, "..", "a.json")); err == nil {
+			t.Errorf("expected an error for a file outside the working directory")
+		}
+	})
+}
+
+func TestFixOutput(t *testing.T) {
+	inTempDir(t, func(dir string) {
+		writeFiles(t, map[string]string{
+			"a.json":      "[1 2]",
+			"conf/b.json": "[3 4]",
+		})
+
+		stats := newRunStats(false, ioutil.Discard)
+		fix([]string{"a.json"}, fixOptions{output: "new/a.json", noCache: true}, stats)
+		expectFiles(t, map[string]string{
+			"a.json":     "[1 2]",
+			"new/a.json": "[1, 2]",
+		})
+		if stats.failed != 0 {
+			t.Errorf("expected no failure, got %d", stats.failed)
+		}
+
+		// the inputs are never overwritten
+		rows := []struct {
+			files []string
+			opts  fixOptions
+		}{
+			{[]string{"a.json"}, fixOptions{output: "a.json"}},
+			{[]string{"a.json"}, fixOptions{output: filepath.Join(dir, "a.json")}},
+			{[]string{"conf/b.json"}, fixOptions{output: "conf/../conf/b.json"}},
+			// mirrored onto themselves
+			{[]string{"a.json"}, fixOptions{outdir: "."}},
+			{[]string{"conf/b.json", "a.json"}, fixOptions{outdir: dir}},
+		}
+		for _, row := range rows {
+			stats := newRunStats(false, ioutil.Discard)
+			opts := row.opts
+			opts.noCache = true
+			fix(row.files, opts, stats)
+			if stats.failed != len(row.files) {
+				t.Errorf("%q with %+v: expected %d failures, got %d", row.files, row.opts, len(row.files), stats.failed)
+			}
+			expectFiles(t, map[string]string{
+				"a.json":      "[1 2]",
+				"conf/b.json": "[3 4]",
+			})
+		}
+	})
+}
+
+func TestFixStats(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"a.json": "[1 2,]",
+			"b.json": "{\"a\": [1, 2]}",
+			"c.json": "[1 2 3]",
+		})
+
+		var out bytes.Buffer
+		stats := newRunStats(true, &out)
+		// a file which fails doesn't stop the others, nor fail the run
+		if err := fix([]string{"a.json", "b.json", "c.json", "missing.json"}, fixOptions{noCache: true}, stats); err != nil {
+			t.Errorf("expected the run to succeed, got %s", err)
+		}
+		stats.report()
+
+		// the files are fixed concurrently, so the lines come in any order
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != 8 {
+			t.Fatalf("expected a line per file and a summary, got:\n%s", out.String())
+		}
+		files := append([]string{}, lines[:4]...)
+		sort.Strings(files)
+		expected := []string{
+			"a.json: changed, 1 commas inserted, 1 removed (6 B)",
+			"b.json: unchanged, 0 commas inserted, 0 removed (13 B)",
+			"c.json: changed, 2 commas inserted, 0 removed (7 B)",
+			"missing.json: failed",
+		}
+		if !reflect.DeepEqual(files, expected) {
+			t.Errorf("actual %q, expected %q", files, expected)
+		}
+		summary := lines[4:6]
+		expected = []string{
+			"",
+			"4 files scanned: 2 changed, 1 unchanged (0 cached), 1 failed",
+		}
+		if !reflect.DeepEqual(summary, expected) {
+			t.Errorf("actual %q, expected %q", summary, expected)
+		}
+		if lines[6] != "3 commas inserted, 1 removed" {
+			t.Errorf("actual %q", lines[6])
+		}
+		if !regexp.MustCompile(`^26 B processed in \S+ \(\S+ \S?B/s\)$`).MatchString(lines[7]) {
+			t.Errorf("unexpected throughput line %q", lines[7])
+		}
+	})
+
+	// nothing is printed without -v
+	var out bytes.Buffer
+	stats := newRunStats(false, &out)
+	stats.add("a.json", jsoncomma.Result{Read: 6, Inserted: 1}, nil)
+	stats.report()
+	if out.Len() != 0 {
+		t.Errorf("expected nothing, got %q", out.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	table := map[int64]string{
+		0:          "0 B",
+		999:        "999 B",
+		1000:       "1.0 kB",
+		1234567:    "1.2 MB",
+		5000000000: "5.0 GB",
+	}
+	for n, expected := range table {
+		if actual := formatBytes(n); actual != expected {
+			t.Errorf("%d: actual %q, expected %q", n, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var defaultGenerate = jsoncomma.DefaultGenerateConfig()
+
+var genCmd = &command{
+	name:  "gen",
+	short: "Generates a random JSON-like document with wrong commas (on stdout)",
+	long: "The document has missing, extra and trailing commas. The same flags always\n" +
+		"give the same document.",
+	flags: flag.NewFlagSet("gen", flag.ExitOnError),
+}
+
+var genSeed = genCmd.flags.Int64("seed", defaultGenerate.Seed, "the seed of the random generator")
+var genSize = genCmd.flags.String("size", "1MB", "the approximate `size` of the document (like 500kB or 10MB)")
+var genOutput = genCmd.flags.String("o", "", "write the document to this `file` instead of stdout")
+var genFixed = genCmd.flags.String("fixed", "", "write what jsoncomma outputs for the document to this `file`")
+var genDepth = genCmd.flags.Int("depth", defaultGenerate.MaxDepth, "the maximum nesting of objects and arrays")
+var genItems = genCmd.flags.Int("items", defaultGenerate.MaxItems, "the maximum number of items of an object or array")
+var genMix = genCmd.flags.String("mix", fmt.Sprintf("strings=%d,numbers=%d,literals=%d,objects=%d,arrays=%d",
+	defaultGenerate.Strings, defaultGenerate.Numbers, defaultGenerate.Literals, defaultGenerate.Objects, defaultGenerate.Arrays),
+	"the relative weights of each kind of value")
+var genComments = genCmd.flags.Float64("comments", defaultGenerate.Comments, "the probability of a comment before an item")
+var genMissing = genCmd.flags.Float64("missing", defaultGenerate.MissingCommas, "the probability of a missing comma")
+var genExtra = genCmd.flags.Float64("extra", defaultGenerate.ExtraCommas, "the probability of extra commas")
+var genTrailing = genCmd.flags.Float64("trailing", defaultGenerate.TrailingCommas, "the probability of a trailing comma")
+
+func init() {
+	genCmd.run = runGen
+}
+
+func runGen(args []string) error {
+	if len(args) != 0 {
+		genCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	size, err := parseSize(*genSize)
+	if err != nil {
+		return err
+	}
+	config := jsoncomma.GenerateConfig{
+		Seed:           *genSeed,
+		Size:           int(size),
+		MaxDepth:       *genDepth,
+		MaxItems:       *genItems,
+		Comments:       *genComments,
+		MissingCommas:  *genMissing,
+		ExtraCommas:    *genExtra,
+		TrailingCommas: *genTrailing,
+	}
+	if err := parseMix(*genMix, &config); err != nil {
+		return err
+	}
+
+	input, fixed := jsoncomma.Generate(config)
+
+	if *genFixed != "" {
+		if err := ioutil.WriteFile(*genFixed, fixed, 0644); err != nil {
+			return err
+		}
+	}
+	if *genOutput != "" {
+		return ioutil.WriteFile(*genOutput, input, 0644)
+	}
+	_, err = os.Stdout.Write(input)
+	return err
+}
+
+// parseSize parses sizes like 10MB (the opposite of formatBytes)
+func parseSize(s string) (int64, error) {
+	number := strings.TrimRight(s, "kKMGTBi")
+	unit := strings.TrimSpace(s[len(number):])
+	multipliers := map[string]int64{
+		"":   1,
+		"B":  1,
+		"kB": 1000,
+		"KB": 1000,
+		"MB": 1000 * 1000,
+		"GB": 1000 * 1000 * 1000,
+	}
+	multiplier, ok := multipliers[unit]
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected something like 500kB or 10MB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// parseMix parses the weights like strings=4,numbers=3
+func parseMix(s string, config *jsoncomma.GenerateConfig) error {
+	weights := map[string]*int{
+		"strings":  &config.Strings,
+		"numbers":  &config.Numbers,
+		"literals": &config.Literals,
+		"objects":  &config.Objects,
+		"arrays":   &config.Arrays,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		weight, ok := weights[strings.TrimSpace(parts[0])]
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("invalid -mix %q, expected kind=weight pairs (kinds: strings, numbers, literals, objects and arrays)", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid weight %q in -mix", parts[1])
+		}
+		*weight = n
+	}
+	return nil
+}
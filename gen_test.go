@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	table := []struct {
+		in   string
+		size int64
+	}{
+		{in: "123", size: 123},
+		{in: "10B", size: 10},
+		{in: "500kB", size: 500 * 1000},
+		{in: "1.5MB", size: 1500 * 1000},
+		{in: "2 GB", size: 2 * 1000 * 1000 * 1000},
+	}
+	for _, row := range table {
+		size, err := parseSize(row.in)
+		if err != nil {
+			t.Errorf("%q: %s", row.in, err)
+		} else if size != row.size {
+			t.Errorf("%q: expected %d, got %d", row.in, row.size, size)
+		}
+	}
+	for _, in := range []string{"", "MB", "10XB", "-1kB"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var installCmd = &command{
+	name:  "install",
+	short: "Copies this executable where plugins expect it",
+	long:  "See the plugin guidelines. The result is printed as JSON.",
+	flags: flag.NewFlagSet("install", flag.ExitOnError),
+}
+
+var installCheck = installCmd.flags.Bool("check", false, "only report whether it's installed, and whether it's the same version\n(exits with status 1 if it isn't)")
+
+func init() {
+	installCmd.run = runInstall
+}
+
+// installPath is where plugins expect the executable to be:
+//
+//	Windows: %APPDATA%\jsoncomma\jsoncomma.exe
+//	Linux:   ~/.config/jsoncomma/jsoncomma
+//	OS X:    ~/Library/Application Support/jsoncomma/jsoncomma
+func installPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the config directory: %s", err)
+	}
+	name := "jsoncomma"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(dir, "jsoncomma", name), nil
+}
+
+// installedVersion runs the executable to get its version
+func installedVersion(path string) (string, error) {
+	output, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s -version: %s", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func runInstall(args []string) error {
+	if len(args) != 0 {
+		installCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+
+	path, err := installPath()
+	if err != nil {
+		return err
+	}
+
+	if *installCheck {
+		result := kv{
+			"kind":      "check",
+			"path":      path,
+			"installed": false,
+			"matches":   false,
+			"expected":  versionLine(),
+		}
+		if _, err := os.Stat(path); err == nil {
+			result["installed"] = true
+			installed, err := installedVersion(path)
+			if err != nil {
+				result["error"] = err.Error()
+			} else {
+				result["version"] = installed
+				result["matches"] = installed == versionLine()
+			}
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		if result["matches"] != true {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if err := install(path); err != nil {
+		encoder.Encode(kv{
+			"kind":  "error",
+			"path":  path,
+			"error": err.Error(),
+		})
+		return exitStatus(1)
+	}
+
+	return encoder.Encode(kv{
+		"kind":    "installed",
+		"path":    path,
+		"version": versionLine(),
+	})
+}
+
+// install copies the running executable to path atomically (copy to a
+// temporary file next to it, and rename), and makes sure it runs
+func install(path string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding the running executable: %s", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+	return installFrom(exe, path)
+}
+
+// installFrom copies the executable exe to path atomically, and makes sure
+// it reports the same version as this one. The copy is checked before it
+// replaces the one at path, so a bad copy never overwrites a working
+// install.
+func installFrom(exe, path string) error {
+	if stat, err := os.Stat(path); err == nil {
+		if exeStat, err := os.Stat(exe); err == nil && os.SameFile(stat, exeStat) {
+			// already running from the install path
+			return verifyInstall(path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".jsoncomma-install-")
+	if err != nil {
+		return err
+	}
+	// no op once it's renamed, removes a bad copy otherwise
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("copying %s: %s", exe, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := verifyInstall(tmp.Name()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func verifyInstall(path string) error {
+	installed, err := installedVersion(path)
+	if err != nil {
+		return err
+	}
+	if installed != versionLine() {
+		return fmt.Errorf("installed executable reports version %q, expected %q", installed, versionLine())
+	}
+	return nil
+}
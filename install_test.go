@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeExecutable writes a script which prints the given version for
+// -version
+func fakeExecutable(t *testing.T, path, version string) {
+	script := "#!/bin/sh\necho '" + version + "'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallFrom(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good")
+	fakeExecutable(t, good, versionLine())
+	bad := filepath.Join(dir, "bad")
+	fakeExecutable(t, bad, "v0.0.1 abc 2020-01-01")
+
+	installDir := filepath.Join(dir, "config", "jsoncomma")
+	path := filepath.Join(installDir, "jsoncomma")
+
+	rows := []struct {
+		exe string
+		err bool
+		// installed is what is at path afterwards
+		installed string
+	}{
+		// creates the directory
+		{good, false, good},
+		// replaces the previous one, even if it's running
+		{good, false, good},
+		// reports the wrong version: the previous one stays
+		{bad, true, good},
+		// already running from the install path
+		{path, false, good},
+	}
+	for i, row := range rows {
+		err := installFrom(row.exe, path)
+		if (err != nil) != row.err {
+			t.Fatalf("%d: installing %s: expected an error: %t, got %v", i, row.exe, row.err, err)
+		}
+		if row.err && !strings.Contains(err.Error(), "version") {
+			t.Errorf("%d: expected a version error, got %s", i, err)
+		}
+
+		expected, err := ioutil.ReadFile(row.installed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(expected) {
+			t.Errorf("%d: expected %s to be installed, got %q", i, row.installed, content)
+		}
+		if stat, err := os.Stat(path); err != nil || stat.Mode().Perm() != 0755 {
+			t.Errorf("%d: expected an executable, got %v (%v)", i, stat.Mode(), err)
+		}
+
+		// the temporary file is always renamed or removed
+		files, err := ioutil.ReadDir(installDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 {
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name())
+			}
+			t.Errorf("%d: expected only the executable, got %q", i, names)
+		}
+	}
+
+	// running from the install path, which reports the wrong version
+	fakeExecutable(t, path, "v0.0.1 abc 2020-01-01")
+	if err := installFrom(path, path); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
+
+func TestVerifyInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "jsoncomma")
+	if err := verifyInstall(path); err == nil {
+		t.Errorf("expected an error when it doesn't exist")
+	}
+	fakeExecutable(t, path, versionLine())
+	if err := verifyInstall(path); err != nil {
+		t.Errorf("expected the version to match: %s", err)
+	}
+	fakeExecutable(t, path, "other")
+	if err := verifyInstall(path); err == nil {
+		t.Errorf("expected the version not to match")
+	}
+}
@@ -0,0 +1,325 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// DetectionThreshold is the confidence above which a payload is considered
+// JSON-like. It's high on purpose: it's much worse to mess up a file which
+// isn't JSON-like than to miss one which is.
+const DetectionThreshold = 0.9
+
+// Detection is the answer to "is this JSON-like?"
+type Detection struct {
+	// JSONLike is true if Confidence >= DetectionThreshold
+	JSONLike bool `json:"jsonlike"`
+	// Confidence is between 0 and 1
+	Confidence float64 `json:"confidence"`
+	// Reason explains the confidence, for humans
+	Reason string `json:"reason"`
+}
+
+// detectionStats are the numbers Detect bases its decision on
+type detectionStats struct {
+	punctuation int
+	strings     int
+	numbers     int
+	literals    int
+	// unknown tokens are things which can't appear in JSON-like content
+	// (identifiers, =, ;, etc)
+	unknown int
+	// missingColons is the number of object keys which aren't followed by
+	// a colon
+	missingColons int
+
+	stringBytes  int
+	commentBytes int
+}
+
+func (s detectionStats) tokens() int {
+	return s.punctuation + s.strings + s.numbers + s.literals + s.unknown
+}
+
+func reject(reason string, args ...interface{}) Detection {
+	return Detection{
+		JSONLike:   false,
+		Confidence: 0,
+		Reason:     fmt.Sprintf(reason, args...),
+	}
+}
+
+// Detect scores how likely content is to be JSON-like (JSON, with comments
+// and with missing/extra commas). It looks at the structure (the brackets
+// must be balanced, and the content must be an object or an array), the mix
+// of tokens, the amount of strings and comments, and rejects binary
+// content.
+func Detect(content []byte) Detection {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	if !utf8.Valid(content) {
+		return reject("binary content (invalid UTF-8)")
+	}
+	control := 0
+	for _, b := range content {
+		if b == 0 {
+			return reject("binary content (NUL byte)")
+		}
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			control++
+		}
+	}
+	if control > 0 {
+		return reject("binary content (%d control characters)", control)
+	}
+
+	var stats detectionStats
+	var stack []detectionFrame
+	first, last := byte(0), byte(0)
+
+	// value is called for each value (string, number, literal, object or
+	// array), to check that objects have keys and colons
+	value := func(isString bool) {
+		if len(stack) == 0 || stack[len(stack)-1].open != '{' {
+			return
+		}
+		frame := &stack[len(stack)-1]
+		switch frame.state {
+		case expectingKey:
+			if isString {
+				frame.state = expectingColon
+			} else {
+				stats.unknown++
+			}
+		case expectingColon:
+			stats.missingColons++
+			frame.state = expectingKey
+		case expectingValue:
+			frame.state = expectingKey
+		}
+	}
+
+	i := 0
+	for i < len(content) {
+		b := content[i]
+		switch {
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			i++
+			continue
+
+		case b == '/' && i+1 < len(content) && content[i+1] == '/':
+			end := bytes.IndexByte(content[i:], '\n')
+			if end == -1 {
+				end = len(content) - i
+			}
+			stats.commentBytes += end
+			i += end
+			continue
+
+		case b == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end == -1 {
+				return reject("unterminated block comment")
+			}
+			stats.commentBytes += end + 4
+			i += end + 4
+			continue
+
+		case b == '"':
+			end := stringEnd(content, i)
+			if end == -1 {
+				return reject("unterminated string")
+			}
+			stats.strings++
+			stats.stringBytes += end - i
+			value(true)
+			i = end
+
+		case b == '{' || b == '[':
+			if len(stack) > 0 && stack[len(stack)-1].open == '{' && stack[len(stack)-1].state != expectingValue {
+				// an object or array as key
+				stats.unknown++
+			}
+			stack = append(stack, detectionFrame{open: b})
+			stats.punctuation++
+			i++
+
+		case b == '}' || b == ']':
+			open := byte('{')
+			if b == ']' {
+				open = '['
+			}
+			if len(stack) == 0 || stack[len(stack)-1].open != open {
+				return reject("unbalanced brackets (unexpected %q at offset %d)", b, i)
+			}
+			if frame := stack[len(stack)-1]; frame.open == '{' && frame.state != expectingKey {
+				stats.missingColons++
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1].open == '{' {
+				stack[len(stack)-1].state = expectingKey
+			}
+			stats.punctuation++
+			i++
+
+		case b == ':':
+			if len(stack) == 0 || stack[len(stack)-1].open != '{' || stack[len(stack)-1].state != expectingColon {
+				stats.unknown++
+			} else {
+				stack[len(stack)-1].state = expectingValue
+				stats.punctuation++
+			}
+			i++
+
+		case b == ',':
+			if len(stack) > 0 && stack[len(stack)-1].open == '{' {
+				if stack[len(stack)-1].state != expectingKey {
+					stats.missingColons++
+				}
+				stack[len(stack)-1].state = expectingKey
+			}
+			stats.punctuation++
+			i++
+
+		case b == '-' || (b >= '0' && b <= '9'):
+			i = numberEnd(content, i)
+			stats.numbers++
+			value(false)
+
+		case isLetter(b):
+			start := i
+			for i < len(content) && (isLetter(content[i]) || (content[i] >= '0' && content[i] <= '9')) {
+				i++
+			}
+			switch string(content[start:i]) {
+			case "true", "false", "null":
+				stats.literals++
+				value(false)
+			default:
+				stats.unknown++
+			}
+
+		default:
+			stats.unknown++
+			_, size := utf8.DecodeRune(content[i:])
+			i += size
+		}
+
+		if first == 0 {
+			first = b
+			if first != '{' && first != '[' {
+				return reject("doesn't start with { or [")
+			}
+		}
+		last = b
+	}
+
+	if first == 0 {
+		return reject("empty (only whitespace and comments)")
+	}
+	if len(stack) != 0 {
+		return reject("unbalanced brackets (%d not closed)", len(stack))
+	}
+	if (first == '{' && last != '}') || (first == '[' && last != ']') {
+		return reject("there is some content after the closing %q", last)
+	}
+
+	return score(stats, len(content))
+}
+
+func score(stats detectionStats, size int) Detection {
+	tokens := stats.tokens()
+	values := stats.strings + stats.numbers + stats.literals
+
+	if values == 0 {
+		return Detection{
+			JSONLike:   false,
+			Confidence: 0.5,
+			Reason:     "no values, too little content to be sure",
+		}
+	}
+
+	commentRatio := float64(stats.commentBytes) / float64(size)
+	stringRatio := float64(stats.stringBytes) / float64(size)
+
+	confidence := 1.0
+	reason := fmt.Sprintf("balanced structure, only JSON tokens (%.0f%% strings, %.0f%% comments)", 100*stringRatio, 100*commentRatio)
+
+	unknownRatio := float64(stats.unknown) / float64(tokens)
+	if stats.unknown > 0 {
+		// every unknown token is a strong sign it's something else
+		confidence -= 10 * unknownRatio
+		reason = fmt.Sprintf("%d tokens which can't be JSON (%.1f%%)", stats.unknown, 100*unknownRatio)
+	}
+
+	if stats.missingColons > 0 {
+		confidence -= 0.5
+		reason = fmt.Sprintf("%d object members without a colon", stats.missingColons)
+	}
+
+	if commentRatio > 0.8 {
+		confidence -= 0.3
+		reason = fmt.Sprintf("mostly comments (%.0f%%)", 100*commentRatio)
+	}
+
+	if confidence < 0 {
+		confidence = 0
+	}
+	return Detection{
+		JSONLike:   confidence >= DetectionThreshold,
+		Confidence: confidence,
+		Reason:     reason,
+	}
+}
+
+// the states of an object while detecting
+const (
+	expectingKey = iota
+	expectingColon
+	expectingValue
+)
+
+// detectionFrame is an object or array Detect is in
+type detectionFrame struct {
+	// open is { or [
+	open byte
+	// state is only used for objects
+	state int
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' || b == '$'
+}
+
+// stringEnd returns the offset right after the string starting at start
+// (which is a quote, ' or "), or -1 if it isn't terminated
+func stringEnd(content []byte, start int) int {
+	quote := content[start]
+	for i := start + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			// JSON strings can't span several lines
+			return -1
+		}
+	}
+	return -1
+}
+
+// numberEnd returns the offset right after the number starting at start
+func numberEnd(content []byte, start int) int {
+	i := start + 1
+	for i < len(content) {
+		b := content[i]
+		if (b >= '0' && b <= '9') || b == '.' || b == 'e' || b == 'E' || b == '+' || b == '-' {
+			i++
+			continue
+		}
+		break
+	}
+	return i
+}
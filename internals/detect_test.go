@@ -0,0 +1,61 @@
+package jsoncomma_test
+
+import (
+	"fmt"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestDetect(t *testing.T) {
+	table := []struct {
+		in       string
+		jsonlike bool
+	}{
+		// JSON-like
+		{in: `{"hello": "world"}`, jsonlike: true},
+		{in: `[1, 2, 3]`, jsonlike: true},
+		{in: "\xef\xbb\xbf" + `{"bom": true}`, jsonlike: true},
+		{in: `{"missing": "comma" "trailing": [1 2 3,],}`, jsonlike: true},
+		{in: "// settings\n{\n\t\"a\": 1 // one\n\t/* block */ \"b\": null\n}\n", jsonlike: true},
+		{in: `[{"nested": {"deeply": [true false null -1.5e10]}}]`, jsonlike: true},
+
+		// not JSON-like
+		{in: ``},
+		{in: "  // only a comment\n"},
+		{in: `{}`},
+		{in: `"just a string"`},
+		{in: `42`},
+		{in: "# title\n\nsome *markdown* [link](http://example.com)\n"},
+		{in: "[section]\nkey = value\n"},
+		{in: "key: value\nlist:\n  - a\n  - b\n"},
+		{in: "function f() { return {\"a\": 1}; }"},
+		{in: "{\n\tvar x = 1;\n\treturn x;\n}"},
+		{in: `{'python': 'dict', 'a': 1}`},
+		{in: `{"css": red; "a": 1}`},
+		{in: `{"a" "b" "c" "d"}`},
+		{in: `[1, 2`},
+		{in: `[1, 2]]`},
+		{in: `{"a": 1} trailing`},
+		{in: `{"unterminated: 1}`},
+		{in: "{\"binary\": \"\x00\"}"},
+		{in: "{\"binary\": \"\xff\xfe\"}"},
+		{in: "{\"control\": \"\x07\"}"},
+		{in: "{ a: 1, b: 2 }"},
+	}
+
+	for _, row := range table {
+		row := row
+		t.Run(fmt.Sprintf("row %#q", row.in), func(t *testing.T) {
+			t.Parallel()
+
+			detection := jsoncomma.Detect([]byte(row.in))
+			if detection.JSONLike != row.jsonlike {
+				t.Errorf("in: %#q, jsonlike: %t (confidence %.2f: %s), expected %t", row.in, detection.JSONLike, detection.Confidence, detection.Reason, row.jsonlike)
+			}
+			if detection.JSONLike != (detection.Confidence >= jsoncomma.DetectionThreshold) {
+				t.Errorf("in: %#q, jsonlike: %t, but confidence is %.2f", row.in, detection.JSONLike, detection.Confidence)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// EditKind is the kind of change made by the Fixer
+type EditKind int
+
+const (
+	// Insert is a comma added
+	Insert EditKind = iota
+	// Remove is a comma removed
+	Remove
+)
+
+func (k EditKind) String() string {
+	if k == Insert {
+		return "insert"
+	}
+	return "remove"
+}
+
+// Edit is a single change made by the Fixer
+type Edit struct {
+	Kind EditKind
+	// Offset is the position in the input. For an insertion, the comma is
+	// inserted before the byte at Offset. For a removal, it's the offset of
+	// the comma.
+	Offset int64
+}
+
+// Edits returns every change Fix would make to in, sorted by offset.
+// Applying all of them (see Apply) gives the same output as Fix.
+func Edits(config *Config, in io.Reader) ([]Edit, error) {
+	edits := []Edit{}
+	if _, err := fix(config, in, ioutil.Discard, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// Apply applies the edits (sorted by offset, as given by Edits) to content.
+// It can be given any subset of the edits.
+func Apply(content []byte, edits []Edit) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content) + len(edits))
+
+	var pos int64
+	for _, edit := range edits {
+		out.Write(content[pos:edit.Offset])
+		pos = edit.Offset
+		if edit.Kind == Insert {
+			out.WriteByte(',')
+		} else {
+			// skip the comma
+			pos++
+		}
+	}
+	out.Write(content[pos:])
+	return out.Bytes()
+}
+
+// end is the offset right after what the edit touches
+func (e Edit) end() int64 {
+	if e.Kind == Remove {
+		return e.Offset + 1
+	}
+	return e.Offset
+}
+
+// Hunks groups the edits (sorted by offset, as given by Edits) which are in
+// the same gap between two values. They must be applied together: a comma
+// moved is an insertion and a removal, and applying only one of them would
+// leave two commas, or none.
+func Hunks(content []byte, edits []Edit) [][]Edit {
+	var hunks [][]Edit
+	for i, edit := range edits {
+		if i > 0 && sameGap(content[edits[i-1].end():edit.Offset]) {
+			hunks[len(hunks)-1] = append(hunks[len(hunks)-1], edit)
+			continue
+		}
+		hunks = append(hunks, []Edit{edit})
+	}
+	return hunks
+}
+
+// sameGap returns true if there are only spaces, commas and comments in
+// between
+func sameGap(between []byte) bool {
+	tokens, err := Tokens(between)
+	if err != nil {
+		return false
+	}
+	for _, token := range tokens {
+		if token.Kind != TokenComma && token.Kind != TokenComment {
+			return false
+		}
+	}
+	return true
+}
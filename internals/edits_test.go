@@ -0,0 +1,142 @@
+package jsoncomma_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestEdits(t *testing.T) {
+	table := []struct {
+		in    string
+		edits []jsoncomma.Edit
+	}{
+		{
+			in:    `[1, 2]`,
+			edits: []jsoncomma.Edit{},
+		},
+		{
+			in:    `[1 2]`,
+			edits: []jsoncomma.Edit{{Kind: jsoncomma.Insert, Offset: 2}},
+		},
+		{
+			in:    `[1, 2,]`,
+			edits: []jsoncomma.Edit{{Kind: jsoncomma.Remove, Offset: 5}},
+		},
+		{
+			in: `[1 ,2,, 3]`,
+			edits: []jsoncomma.Edit{
+				{Kind: jsoncomma.Insert, Offset: 2},
+				{Kind: jsoncomma.Remove, Offset: 3},
+				{Kind: jsoncomma.Remove, Offset: 6},
+			},
+		},
+		{
+			in:    `{, "a": "b" // c, d` + "\n" + `"e": "f",}`,
+			edits: []jsoncomma.Edit{{Kind: jsoncomma.Remove, Offset: 1}, {Kind: jsoncomma.Insert, Offset: 11}, {Kind: jsoncomma.Remove, Offset: 28}},
+		},
+	}
+
+	for _, row := range table {
+		row := row
+		t.Run(fmt.Sprintf("row %#q", row.in), func(t *testing.T) {
+			t.Parallel()
+
+			edits, err := jsoncomma.Edits(&jsoncomma.Config{}, strings.NewReader(row.in))
+			if err != nil {
+				t.Fatalf("in: %#q, err: %s", row.in, err)
+			}
+			if !reflect.DeepEqual(edits, row.edits) {
+				t.Errorf("in: %#q\nactual:   %v\nexpected: %v", row.in, edits, row.edits)
+			}
+		})
+	}
+}
+
+// applying every edit should give the same output as Fix
+func TestApplyEdits(t *testing.T) {
+	inputs := []string{
+		`{ "hello": "world" "oops": "world", }`,
+		"{ \"hello\": 2\n\"oops\": \"test\", }",
+		`["a" 2 4
+		// with comments!
+		{"nested": "keys" // kind of cool
+			"weird":"whitespace, and sneaky [1 2]",}
+		["still" "works"],
+		// i like it
+		]`,
+		`[1 2 3,4,5,6,7, [2, 3, 4],]`,
+		`{"a": 2, "hello\" world": "test", "b": "c",}`,
+		`[,,1,, ,2,]`,
+		"0\t",
+		"/[",
+	}
+
+	for _, in := range inputs {
+		in := in
+		t.Run(fmt.Sprintf("row %#q", in), func(t *testing.T) {
+			t.Parallel()
+
+			var expected bytes.Buffer
+			result, err := jsoncomma.Fix(&jsoncomma.Config{}, strings.NewReader(in), &expected)
+			if err != nil {
+				t.Fatalf("in: %#q, err: %s", in, err)
+			}
+
+			edits, err := jsoncomma.Edits(&jsoncomma.Config{}, strings.NewReader(in))
+			if err != nil {
+				t.Fatalf("in: %#q, err: %s", in, err)
+			}
+			if len(edits) != result.Inserted+result.Removed {
+				t.Errorf("in: %#q, %d edits, but %d inserted and %d removed", in, len(edits), result.Inserted, result.Removed)
+			}
+
+			actual := jsoncomma.Apply([]byte(in), edits)
+			if !bytes.Equal(actual, expected.Bytes()) {
+				t.Errorf("in: %#q\nactual:   %#q\nexpected: %#q", in, actual, expected.String())
+			}
+		})
+	}
+}
+
+func TestHunks(t *testing.T) {
+	table := []struct {
+		in string
+		// the number of edits in each hunk
+		hunks []int
+	}{
+		{in: `[1, 2]`, hunks: nil},
+		{in: `[1 2 3,]`, hunks: []int{1, 1, 1}},
+		// moved commas
+		{in: `[1 ,2]`, hunks: []int{2}},
+		{in: "[1 // c\n, 2]", hunks: []int{2}},
+		{in: "{\"a\": 1\n,\"b\": 2,}", hunks: []int{2, 1}},
+		{in: `[,,1,, ,2,]`, hunks: []int{2, 2, 1}},
+		// the comma in the string isn't in a gap
+		{in: `["," 2 ,]`, hunks: []int{1, 1}},
+	}
+
+	for _, row := range table {
+		edits, err := jsoncomma.Edits(&jsoncomma.Config{}, strings.NewReader(row.in))
+		if err != nil {
+			t.Fatalf("in: %#q, err: %s", row.in, err)
+		}
+		hunks := jsoncomma.Hunks([]byte(row.in), edits)
+		var sizes []int
+		var flattened []jsoncomma.Edit
+		for _, hunk := range hunks {
+			sizes = append(sizes, len(hunk))
+			flattened = append(flattened, hunk...)
+		}
+		if !reflect.DeepEqual(sizes, row.hunks) {
+			t.Errorf("in: %#q\nactual:   %v\nexpected: %v (edits: %v)", row.in, sizes, row.hunks, edits)
+		}
+		if len(flattened) != len(edits) || (len(edits) > 0 && !reflect.DeepEqual(flattened, edits)) {
+			t.Errorf("in: %#q, the hunks %v don't have the edits %v", row.in, hunks, edits)
+		}
+	}
+}
@@ -10,14 +10,18 @@ import (
 
 func FuzzLength(data []byte) int {
 	var buf bytes.Buffer
-	written, err := Fix(&Config{}, bytes.NewReader(data), &buf)
+	result, err := Fix(&Config{}, bytes.NewReader(data), &buf)
 	if err != nil {
 		panic(fmt.Sprintf("error fixing: %s", err))
 	}
+	written := result.Written
 	output := buf.String()
 	if int64(len(output)) != written {
 		panic(fmt.Sprintf("output: %q (%d bytes), yet written = %d", output, len(output), written))
 	}
+	if result.Read != int64(len(data)) {
+		panic(fmt.Sprintf("input: %q (%d bytes), yet read = %d", data, len(data), result.Read))
+	}
 	return 1
 }
 
@@ -31,10 +35,11 @@ func FuzzJson(data []byte) int {
 	}
 	var buf bytes.Buffer
 
-	written, err := Fix(&Config{}, bytes.NewReader(data), &buf)
+	result, err := Fix(&Config{}, bytes.NewReader(data), &buf)
 	if err != nil {
 		panic(fmt.Sprintf("error fixing: %s", err))
 	}
+	written := result.Written
 
 	actual := buf.Bytes()
 	if written != int64(len(actual)) {
@@ -46,3 +51,22 @@ func FuzzJson(data []byte) int {
 	}
 	return 1
 }
+
+// FuzzEdits makes sure that applying the edits gives the same output as Fix
+func FuzzEdits(data []byte) int {
+	var buf bytes.Buffer
+	if _, err := Fix(&Config{}, bytes.NewReader(data), &buf); err != nil {
+		panic(fmt.Sprintf("error fixing: %s", err))
+	}
+
+	edits, err := Edits(&Config{}, bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("error listing edits: %s", err))
+	}
+
+	actual := Apply(data, edits)
+	if !bytes.Equal(actual, buf.Bytes()) {
+		panic(fmt.Sprintf("dismatch:\napplied edits: %#q\nfixed: %#q", actual, buf.Bytes()))
+	}
+	return 1
+}
@@ -0,0 +1,227 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// GenerateConfig configures Generate
+type GenerateConfig struct {
+	Seed int64
+	// Size is the approximate size of the generated input, in bytes
+	Size int
+	// MaxDepth is the maximum nesting of objects and arrays
+	MaxDepth int
+	// MaxItems is the maximum number of members or elements of an object
+	// or array
+	MaxItems int
+
+	// the weights of each kind of value (the higher, the more frequent)
+	Strings  int
+	Numbers  int
+	Literals int
+	Objects  int
+	Arrays   int
+
+	// Comments is the probability of a comment before each item
+	Comments float64
+
+	// the probabilities of each defect, for each comma
+	MissingCommas  float64
+	ExtraCommas    float64
+	TrailingCommas float64
+}
+
+// DefaultGenerateConfig is a mix of everything, about a MB
+func DefaultGenerateConfig() GenerateConfig {
+	return GenerateConfig{
+		Seed:     1,
+		Size:     1000 * 1000,
+		MaxDepth: 6,
+		MaxItems: 8,
+
+		Strings:  4,
+		Numbers:  3,
+		Literals: 1,
+		Objects:  2,
+		Arrays:   1,
+
+		Comments: 0.1,
+
+		MissingCommas:  0.2,
+		ExtraCommas:    0.05,
+		TrailingCommas: 0.2,
+	}
+}
+
+// generator writes the input (with the defects) and the output Fix should
+// give for it at the same time
+type generator struct {
+	config GenerateConfig
+	rand   *rand.Rand
+	in     bytes.Buffer
+	fixed  bytes.Buffer
+}
+
+// Generate generates a JSON-like document (an array of random values, until
+// it's config.Size long) with missing, extra and trailing commas, and the
+// output Fix gives for it. The same config always gives the same document.
+//
+// It only generates what Fix handles: no block comments, and no negative
+// numbers right after a comma (Fix doesn't know that - starts a value).
+func Generate(config GenerateConfig) (input, fixed []byte) {
+	g := &generator{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+
+	g.both("[")
+	for i := 0; g.in.Len() < config.Size || i == 0; i++ {
+		if i > 0 {
+			g.comma()
+		}
+		g.separator("\t")
+		g.value(1, "\t", i > 0)
+	}
+	g.trailing()
+	g.both("\n]\n")
+
+	return g.in.Bytes(), g.fixed.Bytes()
+}
+
+func (g *generator) both(s string) {
+	g.in.WriteString(s)
+	g.fixed.WriteString(s)
+}
+
+func (g *generator) chance(p float64) bool {
+	return g.rand.Float64() < p
+}
+
+// comma writes the comma after an item, maybe missing or doubled
+func (g *generator) comma() {
+	switch {
+	case g.chance(g.config.MissingCommas):
+		g.fixed.WriteString(",")
+	case g.chance(g.config.ExtraCommas):
+		g.in.WriteString(strings.Repeat(",", 2+g.rand.Intn(2)))
+		g.fixed.WriteString(",")
+	default:
+		g.both(",")
+	}
+}
+
+// trailing maybe writes a trailing comma after the last item
+func (g *generator) trailing() {
+	if g.chance(g.config.TrailingCommas) {
+		g.in.WriteString(",")
+	}
+}
+
+// separator goes before each item: a new line, and maybe a comment
+func (g *generator) separator(indent string) {
+	g.both("\n" + indent)
+	if g.chance(g.config.Comments) {
+		g.both("// " + g.words(1+g.rand.Intn(6)) + "\n" + indent)
+	}
+}
+
+var generatorWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing",
+	"elit", "sed", "do", "eiusmod", "tempor", "true", "null", "42", "a,b",
+	"//", "[", "}", "é", "日本",
+}
+
+func (g *generator) words(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = generatorWords[g.rand.Intn(len(generatorWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+func (g *generator) string() string {
+	s := strconv.Quote(g.words(1 + g.rand.Intn(4)))
+	if g.chance(0.1) {
+		// escape sequences, including a backslash before the quote
+		s = s[:len(s)-1] + `\"\\\né\\"`
+	}
+	return s
+}
+
+func (g *generator) number(negative bool) string {
+	var s string
+	switch g.rand.Intn(4) {
+	case 0:
+		s = strconv.Itoa(g.rand.Intn(10))
+	case 1:
+		s = strconv.FormatInt(g.rand.Int63(), 10)
+	case 2:
+		s = strconv.FormatFloat(g.rand.Float64()*1000, 'f', 1+g.rand.Intn(6), 64)
+	default:
+		s = strconv.FormatFloat(g.rand.NormFloat64(), 'e', -1, 64)
+		s = strings.TrimPrefix(s, "-")
+	}
+	if negative && g.chance(0.3) {
+		s = "-" + s
+	}
+	return s
+}
+
+// value writes a random value. afterComma is true if the value comes right
+// after a comma.
+func (g *generator) value(depth int, indent string, afterComma bool) {
+	c := g.config
+	objects, arrays := c.Objects, c.Arrays
+	if depth >= c.MaxDepth {
+		objects, arrays = 0, 0
+	}
+	total := c.Strings + c.Numbers + c.Literals + objects + arrays
+	if total == 0 {
+		g.both("null")
+		return
+	}
+
+	n := g.rand.Intn(total)
+	switch {
+	case n < c.Strings:
+		g.both(g.string())
+	case n < c.Strings+c.Numbers:
+		g.both(g.number(!afterComma))
+	case n < c.Strings+c.Numbers+c.Literals:
+		g.both([]string{"true", "false", "null"}[g.rand.Intn(3)])
+	case n < c.Strings+c.Numbers+c.Literals+objects:
+		g.container(depth, indent, "{", "}", true)
+	default:
+		g.container(depth, indent, "[", "]", false)
+	}
+}
+
+func (g *generator) container(depth int, indent, open, close string, object bool) {
+	items := 0
+	if g.config.MaxItems > 0 {
+		items = g.rand.Intn(g.config.MaxItems + 1)
+	}
+	if items == 0 {
+		g.both(open + close)
+		return
+	}
+
+	g.both(open)
+	for i := 0; i < items; i++ {
+		if i > 0 {
+			g.comma()
+		}
+		g.separator(indent + "\t")
+		if object {
+			g.both(strconv.Quote(g.words(1)) + ": ")
+			g.value(depth+1, indent+"\t", false)
+		} else {
+			g.value(depth+1, indent+"\t", i > 0)
+		}
+	}
+	g.trailing()
+	g.both("\n" + indent + close)
+}
@@ -13,6 +13,14 @@ import (
 
 type Config struct {
 	Logs io.Writer
+	// Version selects the heuristics used to place the commas. The zero
+	// value is V1.
+	Version Version
+	// SingleQuotes makes Fix skip over single quoted strings (JSON5) like
+	// double quoted ones, instead of adding commas inside them. It's off by
+	// default, since ' is just another character in JSON: V1 doesn't know
+	// block comments, so the apostrophe of /* it's */ would start a string.
+	SingleQuotes bool
 }
 
 // when to add a comma
@@ -20,12 +28,23 @@ type Config struct {
 
 type Fixer struct {
 	config *Config
+	// version is the actual version of the heuristics (never Default)
+	version Version
 	in     *bufio.Reader
 	out    *bufio.Writer
 
 	n    int64
 	last byte
 
+	// number of bytes read from in
+	read int64
+	// number of commas inserted and removed
+	inserted int
+	removed  int
+
+	// if it isn't nil, every edit is recorded
+	edits *[]Edit
+
 	log *log.Logger
 }
 
@@ -47,14 +66,36 @@ func (f *Fixer) Write(b []byte) error {
 	return nil
 }
 
+func (f *Fixer) readByte() (byte, error) {
+	b, err := f.in.ReadByte()
+	if err == nil {
+		f.read++
+	}
+	return b, err
+}
+
+func (f *Fixer) unreadByte() error {
+	err := f.in.UnreadByte()
+	if err == nil {
+		f.read--
+	}
+	return err
+}
+
+func (f *Fixer) readBytes(delim byte) ([]byte, error) {
+	bytes, err := f.in.ReadBytes(delim)
+	f.read += int64(len(bytes))
+	return bytes, err
+}
+
 // consumeString reads the entire string and writes it to out, untouched.
-// It handles backslashes (\")
-func (f *Fixer) consumeString() error {
+// It handles backslashes (\"). The opening quote has already been consumed.
+func (f *Fixer) consumeString(quote byte) error {
 	var bytes []byte
 	var err error
 
 	for {
-		bytes, err = f.in.ReadBytes('"')
+		bytes, err = f.readBytes(quote)
 		if err := f.Write(bytes); err != nil {
 			return err
 		}
@@ -82,7 +123,7 @@ func (f *Fixer) consumeString() error {
 			break
 		}
 	}
-	if err := f.insertComma('"'); err != nil {
+	if err := f.insertComma(quote); err != nil {
 		return err
 	}
 
@@ -94,7 +135,7 @@ func (f *Fixer) consumeComment() error {
 	var err error
 
 	// FIXME: better handling of different line endings
-	bytes, err = f.in.ReadBytes('\n')
+	bytes, err = f.readBytes('\n')
 	if err := f.Write(bytes); err != nil {
 		return err
 	}
@@ -108,6 +149,66 @@ func (f *Fixer) consumeComment() error {
 
 }
 
+// readBlockComment reads the rest of a block comment, after the /*, up to
+// and including the */
+func (f *Fixer) readBlockComment() ([]byte, error) {
+	var comment []byte
+	for {
+		bytes, err := f.readBytes('/')
+		comment = append(comment, bytes...)
+		if err != nil {
+			return comment, err
+		}
+		if len(bytes) >= 2 && bytes[len(bytes)-2] == '*' {
+			return comment, nil
+		}
+	}
+}
+
+// consumeBlockComment writes the block comment to out, untouched. The / has
+// already been consumed.
+func (f *Fixer) consumeBlockComment() error {
+	star, err := f.readByte()
+	if err != nil {
+		return err
+	}
+	if err := f.WriteByte(star); err != nil {
+		return err
+	}
+	bytes, readerr := f.readBlockComment()
+	if err := f.Write(bytes); err != nil {
+		return err
+	}
+	if f.log != nil {
+		f.log.Printf("consume block comment: %#q", bytes)
+	}
+	return readerr
+}
+
+// isQuote returns true if b starts a string
+func (f *Fixer) isQuote(b byte) bool {
+	return b == '"' || (f.config.SingleQuotes && b == '\'')
+}
+
+// isPotentialStart is isPotentialStart for the version of the heuristics
+func (f *Fixer) isPotentialStart(b byte) bool {
+	if f.version >= V2 && b == '-' {
+		return true
+	}
+	return isPotentialStart(b) || f.isQuote(b)
+}
+
+// isPotentialEnd is isPotentialEnd, with single quotes if they are enabled
+func (f *Fixer) isPotentialEnd(b byte) bool {
+	return isPotentialEnd(b) || f.isQuote(b)
+}
+
+// isEndPunctuation is isEndPunctuation, with single quotes if they are
+// enabled
+func (f *Fixer) isEndPunctuation(b byte) bool {
+	return isEndPunctuation(b) || f.isQuote(b)
+}
+
 func isPotentialStart(b byte) bool {
 	// f for false, t for true, n for null
 	return isStartPunctuation(b) || (b >= '0' && b <= '9') || b == 'f' || b == 't' || b == 'n'
@@ -129,7 +230,7 @@ func isEndPunctuation(b byte) bool {
 
 func (f *Fixer) insertComma(last byte) (returnerr error) {
 	// last is the last non-whitespace byte
-	if !isPotentialEnd(last) {
+	if !f.isPotentialEnd(last) {
 		return nil
 	}
 
@@ -142,6 +243,17 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 	var bytesRead bytes.Buffer
 	var next byte = ' '
 
+	// the commas we read are dropped, and we insert one back if needed.
+	// If we insert it where one already was, nothing actually changed
+	commasRead := 0
+	commaAtStart := false
+	addComma := false
+
+	// where the comma would be inserted, and the offsets of the commas we
+	// read (only when recording the edits)
+	gapStart := f.read
+	var commaOffsets []int64
+
 	// make sure we always write whatever we read to f.out
 	defer func() {
 		// if we encounter an error in this block, then it overwrites the
@@ -162,6 +274,27 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 			returnerr = fmt.Errorf("writing from internal buffer: wrote %d bytes, expected %d", written, shouldWrite)
 		}
 		f.n += written
+
+		f.removed += commasRead
+		if addComma {
+			if commaAtStart {
+				f.removed--
+				if f.edits != nil {
+					commaOffsets = commaOffsets[1:]
+				}
+			} else {
+				f.inserted++
+			}
+		}
+
+		if f.edits != nil {
+			if addComma && !commaAtStart {
+				*f.edits = append(*f.edits, Edit{Kind: Insert, Offset: gapStart})
+			}
+			for _, offset := range commaOffsets {
+				*f.edits = append(*f.edits, Edit{Kind: Remove, Offset: offset})
+			}
+		}
 	}()
 
 	// here, we have to ignore spaces and comments, in a loop because you can
@@ -171,18 +304,19 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 	// the last and the nextSignificant (otherwise 60 would
 	// result in 6,0)
 	spacesFound := 0
+	gapLength := 0
 	for {
 
 		// the loop here consumes all the spaces
 		for {
-			b, err := f.in.ReadByte()
+			b, err := f.readByte()
 			if err != nil {
 				return err
 			}
 			next = b
 
 			if !unicode.IsSpace(rune(next)) && next != ',' {
-				if err := f.in.UnreadByte(); err != nil {
+				if err := f.unreadByte(); err != nil {
 					return fmt.Errorf("unreading byte: %s", err)
 				}
 				break
@@ -194,8 +328,17 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 				if err := bytesRead.WriteByte(next); err != nil {
 					return fmt.Errorf("writting to internal buffer: %s", err)
 				}
+			} else {
+				if gapLength == 0 {
+					commaAtStart = true
+				}
+				commasRead++
+				if f.edits != nil {
+					commaOffsets = append(commaOffsets, f.read-1)
+				}
 			}
 			spacesFound += 1
+			gapLength++
 		}
 
 		if next != '/' {
@@ -205,14 +348,44 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 		if err != nil {
 			return err
 		}
-		if peek[0] != '/' {
+		isBlock := false
+		if f.version >= V2 {
+			// next was unread, so peek[0] is next itself: the character
+			// after it tells whether it's a comment
+			if peek, _ = f.in.Peek(2); len(peek) < 2 {
+				break
+			}
+			isBlock = peek[1] == '*'
+			if peek[1] != '/' && !isBlock {
+				// we don't actually have a comment
+				break
+			}
+		} else if peek[0] != '/' {
 			// we don't actually have a comment
 			break
 		}
 
 		// consume the comment
 		// we can't use consume comment, because it writes to the buffer
-		bytes, readerr := f.in.ReadBytes('\n')
+		var bytes []byte
+		var readerr error
+		if isBlock {
+			// the /* itself
+			bytes = []byte{'/', '*'}
+			f.readByte()
+			f.readByte()
+			var rest []byte
+			rest, readerr = f.readBlockComment()
+			bytes = append(bytes, rest...)
+		} else {
+			bytes, readerr = f.readBytes('\n')
+		}
+		gapLength += len(bytes)
+		if f.version >= V2 {
+			// a comment separates values like a space (1/* x */2 are two
+			// values)
+			spacesFound++
+		}
 
 		// make sure we write all the bytes we read, even if there is an error
 		written, writeerr := bytesRead.Write(bytes)
@@ -233,12 +406,12 @@ func (f *Fixer) insertComma(last byte) (returnerr error) {
 	// - we are between an end punctuation and a some potential start
 	//     eg ...lue1""val... (last = " and next = ")
 	//     eg ...lue1"true (last = " and next = t)
-	addComma := isEndPunctuation(last) && isPotentialStart(next)
+	addComma = f.isEndPunctuation(last) && f.isPotentialStart(next)
 
 	// - we are between a potential end and a potential start AND THERE IS AT LEAST A SPACE
 	//     eg 123 456 (last = 3 and next = 4).
 	//     we need the space because otherwise 123 would be splited into 1,2,3
-	addComma = addComma || (isPotentialEnd(last) && spacesFound >= 1 && isPotentialStart(next))
+	addComma = addComma || (f.isPotentialEnd(last) && spacesFound >= 1 && f.isPotentialStart(next))
 
 	if addComma {
 		f.WriteByte(',')
@@ -253,7 +426,7 @@ func (f *Fixer) Fix() error {
 	var err error
 	for {
 		prev = b
-		b, err = f.in.ReadByte()
+		b, err = f.readByte()
 		if err != nil {
 			return err
 		}
@@ -266,8 +439,8 @@ func (f *Fixer) Fix() error {
 			}
 		}
 
-		if b == '"' {
-			if err := f.consumeString(); err != nil {
+		if f.isQuote(b) {
+			if err := f.consumeString(b); err != nil {
 				return err
 			}
 		} else if b == '/' {
@@ -280,6 +453,10 @@ func (f *Fixer) Fix() error {
 				if err := f.consumeComment(); err != nil {
 					return err
 				}
+			} else if next[0] == '*' && f.version >= V2 {
+				if err := f.consumeBlockComment(); err != nil {
+					return err
+				}
 			}
 
 			// otherwise, don't do anything. We just peeked at the next
@@ -287,6 +464,10 @@ func (f *Fixer) Fix() error {
 			// by something else
 		} else {
 			if b == ',' {
+				f.removed++
+				if f.edits != nil {
+					*f.edits = append(*f.edits, Edit{Kind: Remove, Offset: f.read - 1})
+				}
 				b = prev
 			}
 			if err := f.insertComma(b); err != nil {
@@ -301,6 +482,57 @@ func (f *Fixer) Written() int64 {
 	return f.n
 }
 
+// Read returns the number of bytes read from the input
+func (f *Fixer) Read() int64 {
+	return f.read
+}
+
+// Inserted returns the number of commas which were added
+func (f *Fixer) Inserted() int {
+	return f.inserted
+}
+
+// Removed returns the number of commas which were removed
+func (f *Fixer) Removed() int {
+	return f.removed
+}
+
+// Result is a summary of what Fix did
+type Result struct {
+	// Read is the number of bytes read from the input
+	Read int64
+	// Written is the number of bytes written to the output
+	Written int64
+	// Inserted is the number of commas which were added
+	Inserted int
+	// Removed is the number of commas which were removed
+	Removed int
+}
+
+// Changed returns true if the output is different from the input
+func (r Result) Changed() bool {
+	return r.Inserted > 0 || r.Removed > 0
+}
+
+// Add adds up the numbers of both results
+func (r Result) Add(other Result) Result {
+	return Result{
+		Read:     r.Read + other.Read,
+		Written:  r.Written + other.Written,
+		Inserted: r.Inserted + other.Inserted,
+		Removed:  r.Removed + other.Removed,
+	}
+}
+
+func (f *Fixer) result() Result {
+	return Result{
+		Read:     f.read,
+		Written:  f.n,
+		Inserted: f.inserted,
+		Removed:  f.removed,
+	}
+}
+
 func (f *Fixer) Flush() error {
 	return f.out.Flush()
 }
@@ -317,8 +549,13 @@ var writersPool = sync.Pool{
 }
 
 // Fix writes everything from in to out, just adding commas where needed
-// returns the number of bytes written, and error
-func Fix(config *Config, in io.Reader, out io.Writer) (int64, error) {
+// returns a summary of what was done (bytes written, commas added, etc), and error
+func Fix(config *Config, in io.Reader, out io.Writer) (Result, error) {
+	return fix(config, in, out, nil)
+}
+
+// fix is Fix, recording the edits if edits isn't nil
+func fix(config *Config, in io.Reader, out io.Writer, edits *[]Edit) (Result, error) {
 
 	var logger *log.Logger
 
@@ -345,10 +582,13 @@ func Fix(config *Config, in io.Reader, out io.Writer) (int64, error) {
 	}()
 
 	f := &Fixer{
-		config: config,
+		config:  config,
+		version: config.Version.Resolve(),
 		in:     bufin,
 		out:    bufout,
 
+		edits: edits,
+
 		log: logger,
 	}
 
@@ -360,9 +600,8 @@ func Fix(config *Config, in io.Reader, out io.Writer) (int64, error) {
 	if err == io.EOF {
 		err = nil
 	}
-	n := f.Written()
 	if err != nil {
-		return n, err
+		return f.result(), err
 	}
-	return n, f.Flush()
+	return f.result(), f.Flush()
 }
@@ -2,10 +2,10 @@ package jsoncomma_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"strings"
 	"testing"
 
@@ -146,14 +146,15 @@ func TestAddCommas(t *testing.T) {
 			var actualNoLogs bytes.Buffer
 			actual.Grow(len(row.out))
 
-			written, err := jsoncomma.Fix(&jsoncomma.Config{&logs}, strings.NewReader(row.in), &actual)
+			result, err := jsoncomma.Fix(&jsoncomma.Config{Logs: &logs}, strings.NewReader(row.in), &actual)
 			if err != nil {
 				t.Fatalf("in: %#q, err: %s", row.in, err)
 			}
-			writtenNoLogs, err := jsoncomma.Fix(&jsoncomma.Config{}, strings.NewReader(row.in), &actualNoLogs)
+			resultNoLogs, err := jsoncomma.Fix(&jsoncomma.Config{}, strings.NewReader(row.in), &actualNoLogs)
 			if err != nil {
 				t.Fatalf("in: %#q, err: %s, only got error *without* the logs", row.in, err)
 			}
+			written, writtenNoLogs := result.Written, resultNoLogs.Written
 			t.Logf("logs\n%s", logs.String())
 
 			if written != writtenNoLogs {
@@ -176,28 +177,198 @@ func TestAddCommas(t *testing.T) {
 	}
 }
 
-func BenchmarkFix(b *testing.B) {
-	b.ReportAllocs()
-	f, err := os.Open("../testdata/random.json")
+func TestVersions(t *testing.T) {
+	table := []struct {
+		in     string
+		v1, v2 string
+	}{
+		{in: `[1 -2]`, v1: `[1 -2]`, v2: `[1, -2]`},
+		{in: `[1e-5 -1]`, v1: `[1e-5 -1]`, v2: `[1e-5, -1]`},
+		{in: `{"a": -1 "b": -2}`, v1: `{"a": -1, "b": -2}`, v2: `{"a": -1, "b": -2}`},
+		{in: `[/* [1 2] */]`, v1: `[/* [1, 2] */]`, v2: `[/* [1 2] */]`},
+		{in: `[1, /* a, b */ 2]`, v1: `[1 /* a, b */ 2]`, v2: `[1, /* a, b */ 2]`},
+		{in: `["a" /* "b" "c" */ "d",]`, v1: `["a" /* "b" "c" */ "d",]`, v2: `["a", /* "b" "c" */ "d"]`},
+		{in: `[1/* x */2]`, v1: `[1/* x */2]`, v2: `[1,/* x */2]`},
+		{in: "[1// c\n2]", v1: "[1// c\n2]", v2: "[1,// c\n2]"},
+		{in: `[1 /* x`, v1: `[1 /* x`, v2: `[1 /* x`},
+		{in: `[1 / 2]`, v1: `[1 / 2]`, v2: `[1 / 2]`},
+	}
+
+	for _, row := range table {
+		// the output of v1 never changes, and it's the default
+		expected := map[jsoncomma.Version]string{
+			jsoncomma.Default: row.v1,
+			jsoncomma.V1:      row.v1,
+			jsoncomma.V2:      row.v2,
+		}
+		for version, out := range expected {
+			config := &jsoncomma.Config{Version: version}
+			var actual bytes.Buffer
+			if _, err := jsoncomma.Fix(config, strings.NewReader(row.in), &actual); err != nil {
+				t.Errorf("%s, in: %#q, err: %s", version, row.in, err)
+				continue
+			}
+			if actual.String() != out {
+				t.Errorf("%s, in: %#q\nactual:   %#q\nexpected: %#q", version, row.in, actual.String(), out)
+			}
 
-	if err != nil {
-		b.Fatalf("opening large JSON file: %s", err)
+			edits, err := jsoncomma.Edits(config, strings.NewReader(row.in))
+			if err != nil {
+				t.Errorf("%s, in: %#q, err: %s", version, row.in, err)
+				continue
+			}
+			if applied := jsoncomma.Apply([]byte(row.in), edits); string(applied) != out {
+				t.Errorf("%s, in: %#q, applying the edits gives %#q, expected %#q", version, row.in, applied, out)
+			}
+		}
 	}
-	for i := 0; i < b.N; i++ {
-		jsoncomma.Fix(&jsoncomma.Config{}, f, ioutil.Discard)
+}
+
+func TestSingleQuotes(t *testing.T) {
+	table := []struct {
+		in string
+		// off is the output without SingleQuotes, on with it
+		off, on string
+	}{
+		{in: `{'a': '1 2' 'b': 3}`, off: `{'a': '1, 2' 'b': 3}`, on: `{'a': '1 2', 'b': 3}`},
+		{in: `['x, y' 'z',]`, off: `['x y' 'z']`, on: `['x, y', 'z']`},
+		{in: `['it\'s 1 2' "3"]`, off: `['it\'s 1, 2' "3"]`, on: `['it\'s 1 2', "3"]`},
+		{in: `[1 'a' "b" 'c']`, off: `[1 'a' "b" 'c']`, on: `[1, 'a', "b", 'c']`},
+		{in: `["it's" 1]`, off: `["it's", 1]`, on: `["it's", 1]`},
+		{in: "[1 // it's\n2]", off: "[1, // it's\n2]", on: "[1, // it's\n2]"},
+	}
+
+	for _, row := range table {
+		for singleQuotes, out := range map[bool]string{false: row.off, true: row.on} {
+			config := &jsoncomma.Config{SingleQuotes: singleQuotes}
+			var actual bytes.Buffer
+			if _, err := jsoncomma.Fix(config, strings.NewReader(row.in), &actual); err != nil {
+				t.Errorf("single quotes %t, in: %#q, err: %s", singleQuotes, row.in, err)
+				continue
+			}
+			if actual.String() != out {
+				t.Errorf("single quotes %t, in: %#q\nactual:   %#q\nexpected: %#q", singleQuotes, row.in, actual.String(), out)
+			}
+		}
 	}
 }
 
-// ideally, Fix is as fast as io.Copy. So, that's our reference
-func BenchmarkRef(b *testing.B) {
+func TestParseVersion(t *testing.T) {
+	table := map[string]jsoncomma.Version{
+		"":        jsoncomma.Default,
+		"default": jsoncomma.Default,
+		"latest":  jsoncomma.V2,
+		"v1":      jsoncomma.V1,
+		"V1":      jsoncomma.V1,
+		"1":       jsoncomma.V1,
+		"v2":      jsoncomma.V2,
+	}
+	for s, expected := range table {
+		if v, err := jsoncomma.ParseVersion(s); err != nil || v != expected {
+			t.Errorf("%q: expected %s, got %s (%v)", s, expected, v, err)
+		}
+	}
+	if _, err := jsoncomma.ParseVersion("v0"); err == nil {
+		t.Errorf("v0: expected an error")
+	}
+	if jsoncomma.Default.Resolve() != jsoncomma.V1 {
+		t.Errorf("expected the default to stay v1, got %s", jsoncomma.Default.Resolve())
+	}
+}
+
+func TestResult(t *testing.T) {
+	table := []struct {
+		in       string
+		inserted int
+		removed  int
+	}{
+		{in: `[1, 2, 3]`},
+		{in: `[1 2 3]`, inserted: 2},
+		{in: `[1, 2, 3,]`, removed: 1},
+		{in: `[1,, 2 3,]`, inserted: 1, removed: 2},
+		{in: `[, 1]`, removed: 1},
+		// the comma is moved
+		{in: `[1 ,2]`, inserted: 1, removed: 1},
+		{in: "{\"a\": 1 // comment, with a comma\n\"b\": 2}", inserted: 1},
+		{in: `{"a": "b,"}`},
+		{in: `[1,`, removed: 1},
+	}
+
+	for _, row := range table {
+		row := row
+		t.Run(fmt.Sprintf("row %#q", row.in), func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			result, err := jsoncomma.Fix(&jsoncomma.Config{}, strings.NewReader(row.in), &out)
+			if err != nil {
+				t.Fatalf("in: %#q, err: %s", row.in, err)
+			}
+			if result.Read != int64(len(row.in)) {
+				t.Errorf("in: %#q, read %d bytes, expected %d", row.in, result.Read, len(row.in))
+			}
+			if result.Written != int64(out.Len()) {
+				t.Errorf("in: %#q, written %d bytes, expected %d", row.in, result.Written, out.Len())
+			}
+			if result.Inserted != row.inserted || result.Removed != row.removed {
+				t.Errorf("in: %#q, output: %#q, inserted %d and removed %d, expected %d and %d", row.in, out.String(), result.Inserted, result.Removed, row.inserted, row.removed)
+			}
+			if result.Changed() != (row.in != out.String()) {
+				t.Errorf("in: %#q, output: %#q, changed: %t", row.in, out.String(), result.Changed())
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		config := jsoncomma.DefaultGenerateConfig()
+		config.Seed = seed
+		config.Size = 20 * 1000
+		if seed%2 == 0 {
+			config.Comments = 0
+		}
+		input, expected := jsoncomma.Generate(config)
+
+		if again, _ := jsoncomma.Generate(config); !bytes.Equal(input, again) {
+			t.Fatalf("seed %d: two different documents", seed)
+		}
+
+		var out bytes.Buffer
+		if _, err := jsoncomma.Fix(&jsoncomma.Config{}, bytes.NewReader(input), &out); err != nil {
+			t.Fatalf("seed %d: %s", seed, err)
+		}
+		if !bytes.Equal(out.Bytes(), expected) {
+			t.Errorf("seed %d: the output isn't the expected one (input: %q)", seed, input)
+		}
+		if config.Comments == 0 && !json.Valid(expected) {
+			t.Errorf("seed %d: the expected output isn't valid JSON", seed)
+		}
+	}
+}
+
+// benchmarkInput is the same large document for every benchmark
+func benchmarkInput(b *testing.B) []byte {
+	config := jsoncomma.DefaultGenerateConfig()
+	config.Size = 10 * 1000 * 1000
+	input, _ := jsoncomma.Generate(config)
+	b.SetBytes(int64(len(input)))
 	b.ReportAllocs()
-	f, err := os.Open("../testdata/random.json")
+	b.ResetTimer()
+	return input
+}
 
-	if err != nil {
-		b.Fatalf("opening large JSON file: %s", err)
+func BenchmarkFix(b *testing.B) {
+	input := benchmarkInput(b)
+	for i := 0; i < b.N; i++ {
+		jsoncomma.Fix(&jsoncomma.Config{}, bytes.NewReader(input), ioutil.Discard)
 	}
+}
 
+// ideally, Fix is as fast as io.Copy. So, that's our reference
+func BenchmarkRef(b *testing.B) {
+	input := benchmarkInput(b)
 	for i := 0; i < b.N; i++ {
-		io.Copy(ioutil.Discard, f)
+		io.Copy(ioutil.Discard, bytes.NewReader(input))
 	}
 }
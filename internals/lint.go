@@ -0,0 +1,467 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity is how bad a lint problem is
+type Severity int
+
+const (
+	// Off disables a rule
+	Off Severity = iota
+	Info
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "off"
+}
+
+// ParseSeverity is the opposite of Severity.String
+func ParseSeverity(s string) (Severity, error) {
+	for _, severity := range []Severity{Off, Info, Warning, Error} {
+		if severity.String() == s {
+			return severity, nil
+		}
+	}
+	return Off, fmt.Errorf("unknown severity %q, expected off, info, warning or error", s)
+}
+
+// The lint rules
+const (
+	// RuleCommas reports the commas Fix would insert or remove. Fix
+	// doesn't know unquoted keys, so the rule only leaves a note on files
+	// which have some.
+	RuleCommas = "commas"
+	// RuleDuplicateKeys reports keys defined several times in an object
+	RuleDuplicateKeys = "duplicate-keys"
+	// RuleEmptyKeys reports "" keys
+	RuleEmptyKeys = "empty-keys"
+	// RuleMaxDepth reports objects and arrays nested too deeply
+	RuleMaxDepth = "max-depth"
+	// RuleFloatPrecision reports numbers which change once parsed as
+	// float64 (what most JSON parsers do)
+	RuleFloatPrecision = "float-precision"
+	// RuleMixedLineEndings reports files with both \n and \r\n
+	RuleMixedLineEndings = "mixed-line-endings"
+	// RuleQuoteStyle reports strings and keys which aren't quoted in the
+	// expected style
+	RuleQuoteStyle = "quote-style"
+)
+
+// Rules is every lint rule
+var Rules = []string{
+	RuleCommas,
+	RuleDuplicateKeys,
+	RuleEmptyKeys,
+	RuleMaxDepth,
+	RuleFloatPrecision,
+	RuleMixedLineEndings,
+	RuleQuoteStyle,
+}
+
+// RuleConfig configures a lint rule
+type RuleConfig struct {
+	Severity Severity
+	// Max is the maximum depth (max-depth only)
+	Max int
+	// Style is double, single or consistent (quote-style only, in JSON5
+	// mode). Consistent means like the first string.
+	Style string
+}
+
+// LintConfig configures Lint
+type LintConfig struct {
+	// JSON5 allows single quoted strings and unquoted keys
+	JSON5 bool
+	// Version is the version of the heuristics the commas rule uses
+	Version Version
+	// Rules missing from the map are off
+	Rules map[string]RuleConfig
+}
+
+// DefaultLintConfig enables every rule
+func DefaultLintConfig() LintConfig {
+	return LintConfig{
+		Rules: map[string]RuleConfig{
+			RuleCommas:           {Severity: Error},
+			RuleDuplicateKeys:    {Severity: Error},
+			RuleEmptyKeys:        {Severity: Warning},
+			RuleMaxDepth:         {Severity: Warning, Max: 20},
+			RuleFloatPrecision:   {Severity: Warning},
+			RuleMixedLineEndings: {Severity: Warning},
+			RuleQuoteStyle:       {Severity: Warning, Style: "consistent"},
+		},
+	}
+}
+
+// Replacement replaces content[Start:End] with Text
+type Replacement struct {
+	Start, End int
+	Text       string
+}
+
+// Problem is something a lint rule found
+type Problem struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	Offset   int
+	Line     int
+	Column   int
+	// Fix is the autofix, if the rule has a safe one
+	Fix []Replacement
+}
+
+type linter struct {
+	content  []byte
+	config   LintConfig
+	problems []Problem
+}
+
+func (l *linter) rule(name string) (RuleConfig, bool) {
+	rule, ok := l.config.Rules[name]
+	return rule, ok && rule.Severity != Off
+}
+
+func (l *linter) report(rule string, offset int, fix []Replacement, format string, args ...interface{}) {
+	line, column := Position(l.content, offset)
+	l.problems = append(l.problems, Problem{
+		Rule:     rule,
+		Severity: l.config.Rules[rule].Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+		Fix:      fix,
+	})
+}
+
+// lintFrame is an object or an array the linter is in
+type lintFrame struct {
+	open  TokenKind
+	state int
+	// keys are the offsets of the keys seen so far (objects only)
+	keys map[string]int
+}
+
+// Lint checks content against the enabled rules. It works on the tokens
+// of the Scanner (and on the edits of the Fixer for the commas), so it
+// works on content which doesn't parse. The Fixer only streams its output,
+// it doesn't give tokens: the Scanner is what Parse, Set and Reduce use to
+// see the same content. The problems are sorted by offset.
+func Lint(content []byte, config LintConfig) ([]Problem, error) {
+	l := &linter{content: content, config: config}
+
+	tokens, err := Tokens(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := l.rule(RuleCommas); ok {
+		if err := l.commas(tokens); err != nil {
+			return nil, err
+		}
+	}
+
+	l.tokens(tokens)
+	l.lineEndings()
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Offset < l.problems[j].Offset
+	})
+	return l.problems, nil
+}
+
+// commas reports the edits of the Fixer (which skips over single quoted
+// strings). It doesn't know unquoted keys, and would remove the comma
+// before them: files with some only get a note saying that the commas
+// weren't checked.
+func (l *linter) commas(tokens []Token) error {
+	for _, token := range tokens {
+		if token.Kind == TokenIdent {
+			l.report(RuleCommas, token.Offset, nil, "commas not checked: the fixer doesn't know unquoted keys like %s", token.Text)
+			l.problems[len(l.problems)-1].Severity = Info
+			return nil
+		}
+	}
+
+	edits, err := Edits(&Config{Version: l.config.Version, SingleQuotes: true}, bytes.NewReader(l.content))
+	if err != nil {
+		return err
+	}
+	for _, edit := range edits {
+		offset := int(edit.Offset)
+		if edit.Kind == Insert {
+			l.report(RuleCommas, offset, []Replacement{{Start: offset, End: offset, Text: ","}}, "missing comma")
+		} else {
+			l.report(RuleCommas, offset, []Replacement{{Start: offset, End: offset + 1}}, "extra comma")
+		}
+	}
+	return nil
+}
+
+func (l *linter) tokens(tokens []Token) {
+	maxDepth, checkDepth := l.rule(RuleMaxDepth)
+	var stack []lintFrame
+	// the quote style of the first string, for the consistent style
+	var firstQuote byte
+
+	// value is called after each value
+	value := func() {
+		if len(stack) > 0 && stack[len(stack)-1].open == TokenBeginObject {
+			stack[len(stack)-1].state = expectingKey
+		}
+	}
+
+	for _, token := range tokens {
+		var frame *lintFrame
+		if len(stack) > 0 {
+			frame = &stack[len(stack)-1]
+		}
+		isKey := frame != nil && frame.open == TokenBeginObject && frame.state == expectingKey &&
+			(token.Kind == TokenString || token.Kind == TokenIdent || token.Kind == TokenLiteral)
+
+		if token.Kind == TokenString || isKey {
+			if firstQuote == 0 && token.Kind == TokenString {
+				firstQuote = token.Text[0]
+			}
+			l.quoteStyle(token, firstQuote)
+		}
+
+		switch token.Kind {
+		case TokenBeginObject, TokenBeginArray:
+			stack = append(stack, lintFrame{open: token.Kind, keys: map[string]int{}})
+			if checkDepth && len(stack) == maxDepth.Max+1 {
+				l.report(RuleMaxDepth, token.Offset, nil, "nested too deeply (more than %d levels)", maxDepth.Max)
+			}
+		case TokenEndObject, TokenEndArray:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			value()
+		case TokenColon:
+			if frame != nil && frame.state == expectingColon {
+				frame.state = expectingValue
+			}
+		case TokenComment, TokenComma, TokenInvalid:
+		default:
+			if isKey {
+				l.key(frame, token)
+				frame.state = expectingColon
+				continue
+			}
+			if token.Kind == TokenNumber {
+				l.floatPrecision(token)
+			}
+			value()
+		}
+	}
+}
+
+func (l *linter) key(frame *lintFrame, token Token) {
+	key := token.Text
+	if token.Kind == TokenString {
+		var err error
+		if key, err = Unquote(token.Text); err != nil {
+			return
+		}
+	}
+
+	if _, ok := l.rule(RuleEmptyKeys); ok && key == "" {
+		l.report(RuleEmptyKeys, token.Offset, nil, "empty key")
+	}
+	if _, ok := l.rule(RuleDuplicateKeys); ok {
+		if previous, ok := frame.keys[key]; ok {
+			line, column := Position(l.content, previous)
+			l.report(RuleDuplicateKeys, token.Offset, nil, "duplicate key %s (first defined at %d:%d)", token.Text, line, column)
+		} else {
+			frame.keys[key] = token.Offset
+		}
+	}
+}
+
+func (l *linter) quoteStyle(token Token, firstQuote byte) {
+	rule, ok := l.rule(RuleQuoteStyle)
+	if !ok {
+		return
+	}
+
+	if token.Kind != TokenString {
+		// unquoted key
+		if !l.config.JSON5 {
+			l.report(RuleQuoteStyle, token.Offset, l.requote(token, '"', token.Text), "unquoted key %s isn't valid JSON", token.Text)
+		}
+		return
+	}
+
+	expected := byte('"')
+	if l.config.JSON5 {
+		switch rule.Style {
+		case "single":
+			expected = '\''
+		case "double":
+		default:
+			expected = firstQuote
+		}
+	}
+	if token.Text[0] == expected {
+		return
+	}
+	s, err := Unquote(token.Text)
+	if err != nil {
+		return
+	}
+	if !l.config.JSON5 {
+		l.report(RuleQuoteStyle, token.Offset, l.requote(token, expected, s), "single quoted strings aren't valid JSON")
+	} else {
+		l.report(RuleQuoteStyle, token.Offset, l.requote(token, expected, s), "expected %c quotes", expected)
+	}
+}
+
+func (l *linter) requote(token Token, quote byte, s string) []Replacement {
+	return []Replacement{{Start: token.Offset, End: token.End(), Text: Quote(s, quote)}}
+}
+
+// Quote quotes s with double or single quotes
+func Quote(s string, quote byte) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// strings can always be encoded
+	encoder.Encode(s)
+	double := strings.TrimSuffix(buf.String(), "\n")
+	if quote == '"' {
+		return double
+	}
+	// \" isn't needed anymore, and ' needs to be escaped
+	inner := double[1 : len(double)-1]
+	inner = strings.Replace(inner, `\"`, `"`, -1)
+	inner = strings.Replace(inner, `'`, `\'`, -1)
+	return "'" + inner + "'"
+}
+
+func (l *linter) floatPrecision(token Token) {
+	if _, ok := l.rule(RuleFloatPrecision); !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(token.Text, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			l.report(RuleFloatPrecision, token.Offset, nil, "%s is out of the range of float64", token.Text)
+		}
+		return
+	}
+	// the shortest representation of the float is what a parser would
+	// write back
+	shortest := strconv.FormatFloat(f, 'g', -1, 64)
+	parsed, _ := new(big.Rat).SetString(shortest)
+	// big.Rat refuses exponents too large for it (like 1e-9999999, which
+	// isn't 0, but becomes 0)
+	exact, ok := new(big.Rat).SetString(token.Text)
+	if !ok || exact.Cmp(parsed) != 0 {
+		l.report(RuleFloatPrecision, token.Offset, nil, "%s loses precision as a float64 (becomes %s)", token.Text, shortest)
+	}
+}
+
+func (l *linter) lineEndings() {
+	if _, ok := l.rule(RuleMixedLineEndings); !ok {
+		return
+	}
+	var crlf, lf []int
+	for i, b := range l.content {
+		if b != '\n' {
+			continue
+		}
+		if i > 0 && l.content[i-1] == '\r' {
+			crlf = append(crlf, i-1)
+		} else {
+			lf = append(lf, i)
+		}
+	}
+	if len(crlf) == 0 || len(lf) == 0 {
+		return
+	}
+
+	// use the most common line ending
+	var fix []Replacement
+	var offsets []int
+	if len(crlf) >= len(lf) {
+		offsets = lf
+		for _, offset := range lf {
+			fix = append(fix, Replacement{Start: offset, End: offset + 1, Text: "\r\n"})
+		}
+	} else {
+		offsets = crlf
+		for _, offset := range crlf {
+			fix = append(fix, Replacement{Start: offset, End: offset + 2, Text: "\n"})
+		}
+	}
+	l.report(RuleMixedLineEndings, offsets[0], fix, "mixed line endings (%d \\r\\n and %d \\n)", len(crlf), len(lf))
+}
+
+// ApplyFixes applies the autofixes of the problems to content. Fixes which
+// overlap with one which is already applied are skipped.
+func ApplyFixes(content []byte, problems []Problem) []byte {
+	var replacements []Replacement
+	for _, problem := range problems {
+		replacements = append(replacements, problem.Fix...)
+	}
+	sort.SliceStable(replacements, func(i, j int) bool {
+		return replacements[i].Start < replacements[j].Start
+	})
+
+	var out bytes.Buffer
+	pos := 0
+	for _, r := range replacements {
+		if r.Start < pos {
+			continue
+		}
+		out.Write(content[pos:r.Start])
+		out.WriteString(r.Text)
+		pos = r.End
+	}
+	out.Write(content[pos:])
+	return out.Bytes()
+}
+
+// maxFixPasses is the maximum number of times Autofix applies the fixes
+const maxFixPasses = 10
+
+// Autofix applies the autofixes until there is nothing left to fix (a fix
+// can make others necessary: a key which gets quoted needs a comma before
+// it, for example). It returns the fixed content, and the problems which
+// are left.
+func Autofix(content []byte, config LintConfig) ([]byte, []Problem, error) {
+	problems, err := Lint(content, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	for pass := 0; pass < maxFixPasses; pass++ {
+		fixed := ApplyFixes(content, problems)
+		if bytes.Equal(fixed, content) {
+			break
+		}
+		content = fixed
+		if problems, err = Lint(content, config); err != nil {
+			return nil, nil, err
+		}
+	}
+	return content, problems, nil
+}
@@ -0,0 +1,146 @@
+package jsoncomma_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestLint(t *testing.T) {
+	table := []struct {
+		in    string
+		json5 bool
+		// version is the version of the commas heuristics
+		version jsoncomma.Version
+		// problems are "line:column rule"
+		problems []string
+		fixed    string
+	}{
+		{
+			in:       `{"a": 1 "b": 2,}`,
+			problems: []string{"1:8 commas", "1:15 commas"},
+			fixed:    `{"a": 1, "b": 2}`,
+		},
+		{
+			in:       `{"a": 1, "b": {"a": 2}, "a": 3, "": 4}`,
+			problems: []string{"1:25 duplicate-keys", "1:33 empty-keys"},
+			fixed:    `{"a": 1, "b": {"a": 2}, "a": 3, "": 4}`,
+		},
+		{
+			in:       `[[[[1]]]]`,
+			problems: []string{"1:4 max-depth"},
+			fixed:    `[[[[1]]]]`,
+		},
+		{
+			in:       `[0.1, 1e400, 9007199254740993, 9007199254740992]`,
+			problems: []string{"1:7 float-precision", "1:14 float-precision"},
+			fixed:    `[0.1, 1e400, 9007199254740993, 9007199254740992]`,
+		},
+		{
+			// too large for big.Rat
+			in:       `[1e9999999, 1e-9999999, 0e-9999999, 1e999999]`,
+			problems: []string{"1:2 float-precision", "1:13 float-precision", "1:37 float-precision"},
+			fixed:    `[1e9999999, 1e-9999999, 0e-9999999, 1e999999]`,
+		},
+		{
+			in:       "[\r\n1,\r\n2\n]",
+			problems: []string{"3:2 mixed-line-endings"},
+			fixed:    "[\r\n1,\r\n2\r\n]",
+		},
+		{
+			// the fixer doesn't know unquoted keys: a note says the commas
+			// aren't checked
+			in:       `{'a': "it's", b: 1}`,
+			problems: []string{"1:2 quote-style", "1:15 commas", "1:15 quote-style"},
+			fixed:    `{"a": "it's", "b": 1}`,
+		},
+		{
+			// the commas of single quoted strings are just text
+			in:       `{'a': '1 2', 'b': ['x, y', 3]}`,
+			problems: []string{"1:2 quote-style", "1:7 quote-style", "1:14 quote-style", "1:20 quote-style"},
+			fixed:    `{"a": "1 2", "b": ["x, y", 3]}`,
+		},
+		{
+			in:       `['a' 'b']`,
+			problems: []string{"1:2 quote-style", "1:5 commas", "1:6 quote-style"},
+			fixed:    `["a", "b"]`,
+		},
+		{
+			in:       `[1 -2]`,
+			version:  jsoncomma.V1,
+			problems: nil,
+			fixed:    `[1 -2]`,
+		},
+		{
+			in:       `[1 -2]`,
+			version:  jsoncomma.V2,
+			problems: []string{"1:3 commas"},
+			fixed:    `[1, -2]`,
+		},
+		{
+			in:       `{'a': "it's", b: 1}`,
+			json5:    true,
+			problems: []string{"1:7 quote-style", "1:15 commas"},
+			fixed:    `{'a': 'it\'s', b: 1}`,
+		},
+		{
+			in:       `{'a': 'x y' 'b': [1 'z',]}`,
+			json5:    true,
+			problems: []string{"1:12 commas", "1:20 commas", "1:24 commas"},
+			fixed:    `{'a': 'x y', 'b': [1, 'z']}`,
+		},
+	}
+
+	for _, row := range table {
+		config := jsoncomma.DefaultLintConfig()
+		config.JSON5 = row.json5
+		config.Version = row.version
+		config.Rules[jsoncomma.RuleMaxDepth] = jsoncomma.RuleConfig{Severity: jsoncomma.Warning, Max: 3}
+
+		problems, err := jsoncomma.Lint([]byte(row.in), config)
+		if err != nil {
+			t.Errorf("%q: %s", row.in, err)
+			continue
+		}
+		var got []string
+		for _, problem := range problems {
+			got = append(got, fmt.Sprintf("%d:%d %s", problem.Line, problem.Column, problem.Rule))
+		}
+		if !reflect.DeepEqual(got, row.problems) {
+			t.Errorf("%q: expected problems %q, got %q", row.in, row.problems, got)
+		}
+		fixed, _, err := jsoncomma.Autofix([]byte(row.in), config)
+		if err != nil {
+			t.Errorf("%q: autofix: %s", row.in, err)
+		} else if string(fixed) != row.fixed {
+			t.Errorf("%q: expected fixed %q, got %q", row.in, row.fixed, fixed)
+		}
+	}
+}
+
+func TestLintRuleOff(t *testing.T) {
+	config := jsoncomma.DefaultLintConfig()
+	config.Rules[jsoncomma.RuleCommas] = jsoncomma.RuleConfig{Severity: jsoncomma.Off}
+	problems, err := jsoncomma.Lint([]byte(`[1 2]`), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("expected no problem, got %v", problems)
+	}
+}
+
+func TestLintCommasNote(t *testing.T) {
+	config := jsoncomma.DefaultLintConfig()
+	config.JSON5 = true
+	problems, err := jsoncomma.Lint([]byte(`{a: 1 "b": 2}`), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the missing comma isn't reported, but the note doesn't fail the lint
+	if len(problems) != 1 || problems[0].Rule != jsoncomma.RuleCommas || problems[0].Severity != jsoncomma.Info {
+		t.Errorf("expected a note that the commas aren't checked, got %+v", problems)
+	}
+}
@@ -0,0 +1,109 @@
+package jsoncomma
+
+import (
+	"fmt"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to base, editing base's
+// text like Set and Delete: its comments and formatting are kept. The
+// comments of the patch's members are carried over (in the objects which
+// span several lines).
+//
+// Members set to null are removed, objects are merged recursively, and
+// everything else replaces what is in base.
+func MergePatch(config *Config, base, patch []byte) ([]byte, error) {
+	patchDoc, err := Parse(patch)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the patch: %s", err)
+	}
+	if _, err := Parse(base); err != nil {
+		return nil, fmt.Errorf("parsing the base: %s", err)
+	}
+	return mergeValue(config, base, "", patch, patchDoc.Root, carriedComments{})
+}
+
+// mergeValue merges the patch value v (in the patch's content) into the
+// value at pointer in base
+func mergeValue(config *Config, base []byte, pointer string, patch []byte, v *Value, comments carriedComments) ([]byte, error) {
+	if v.Kind != Object {
+		return set(config, base, pointer, patch[v.Start:v.End], comments)
+	}
+
+	doc, err := Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := locate(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if loc.value == nil || loc.value.Kind != Object {
+		// replaced by the patch, without its nulls
+		value, err := withoutNulls(config, patch[v.Start:v.End])
+		if err != nil {
+			return nil, err
+		}
+		return set(config, base, pointer, value, comments)
+	}
+
+	// the object stays, but gets the comments
+	if at, text := leadingComments(base, loc, comments.leading); text != "" {
+		if base, err = splice(config, base, at, at, at, text); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, member := range v.Members {
+		memberPointer := pointer + FormatPointer(member.Key)
+		if member.Value.Kind == Null {
+			doc, err := Parse(base)
+			if err != nil {
+				return nil, err
+			}
+			if value, _ := doc.Get(memberPointer); value == nil {
+				continue
+			}
+			if base, err = Delete(config, base, memberPointer); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		carried := carriedComments{leading: member.Value.Comments, line: member.Value.LineComment}
+		if base, err = mergeValue(config, base, memberPointer, patch, member.Value, carried); err != nil {
+			return nil, err
+		}
+	}
+	return base, nil
+}
+
+// withoutNulls removes the members which are null (recursively, but not
+// the nulls in arrays, which are values)
+func withoutNulls(config *Config, content []byte) ([]byte, error) {
+	for {
+		doc, err := Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		pointer, ok := findNull(doc.Root, "")
+		if !ok {
+			return content, nil
+		}
+		if content, err = Delete(config, content, pointer); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func findNull(v *Value, pointer string) (string, bool) {
+	for _, member := range v.Members {
+		memberPointer := pointer + FormatPointer(member.Key)
+		if member.Value.Kind == Null {
+			return memberPointer, true
+		}
+		if found, ok := findNull(member.Value, memberPointer); ok {
+			return found, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,92 @@
+package jsoncomma_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// the examples of RFC 7386, appendix A
+func TestMergePatchRFC(t *testing.T) {
+	table := []struct {
+		base, patch, result string
+	}{
+		{base: `{"a":"b"}`, patch: `{"a":"c"}`, result: `{"a":"c"}`},
+		{base: `{"a":"b"}`, patch: `{"b":"c"}`, result: `{"a":"b","b":"c"}`},
+		{base: `{"a":"b"}`, patch: `{"a":null}`, result: `{}`},
+		{base: `{"a":"b","b":"c"}`, patch: `{"a":null}`, result: `{"b":"c"}`},
+		{base: `{"a":["b"]}`, patch: `{"a":"c"}`, result: `{"a":"c"}`},
+		{base: `{"a":"c"}`, patch: `{"a":["b"]}`, result: `{"a":["b"]}`},
+		{base: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, result: `{"a":{"b":"d"}}`},
+		{base: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, result: `{"a":[1]}`},
+		{base: `["a","b"]`, patch: `["c","d"]`, result: `["c","d"]`},
+		{base: `{"a":"b"}`, patch: `["c"]`, result: `["c"]`},
+		{base: `{"a":"foo"}`, patch: `null`, result: `null`},
+		{base: `{"a":"foo"}`, patch: `"bar"`, result: `"bar"`},
+		{base: `{"e":null}`, patch: `{"a":1}`, result: `{"e":null,"a":1}`},
+		{base: `[1,2]`, patch: `{"a":"b","c":null}`, result: `{"a":"b"}`},
+		{base: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, result: `{"a":{"bb":{}}}`},
+	}
+
+	for _, row := range table {
+		merged, err := jsoncomma.MergePatch(&jsoncomma.Config{}, []byte(row.base), []byte(row.patch))
+		if err != nil {
+			t.Errorf("%s + %s: %s", row.base, row.patch, err)
+			continue
+		}
+		var fixed bytes.Buffer
+		if _, err := jsoncomma.Fix(&jsoncomma.Config{}, bytes.NewReader(merged), &fixed); err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		if err := json.Unmarshal(fixed.Bytes(), &got); err != nil {
+			t.Errorf("%s + %s: invalid result %s: %s", row.base, row.patch, fixed.Bytes(), err)
+			continue
+		}
+		json.Unmarshal([]byte(row.result), &expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s + %s: expected %s, got %s", row.base, row.patch, row.result, fixed.Bytes())
+		}
+	}
+}
+
+func TestMergePatchKeepsComments(t *testing.T) {
+	base := "{\n\t// the port\n\t\"port\": 8080, // default\n\t\"db\": {\n\t\t\"host\": \"localhost\"\n\t}\n}"
+	patch := "{\n\t// in production\n\t\"port\": 80,\n\t\"db\": {\n\t\t// managed\n\t\t\"user\": \"app\" // from the vault\n\t}\n}"
+	expected := "{\n\t// the port\n\t// in production\n\t\"port\": 80, // default\n\t\"db\": {\n\t\t\"host\": \"localhost\",\n\t\t// managed\n\t\t\"user\": \"app\" // from the vault\n\t}\n}"
+
+	merged, err := jsoncomma.MergePatch(&jsoncomma.Config{}, []byte(base), []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(merged) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, merged)
+	}
+
+	// comments aren't added twice
+	again, err := jsoncomma.MergePatch(&jsoncomma.Config{}, merged, []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != expected {
+		t.Errorf("merging twice: expected\n%s\ngot\n%s", expected, again)
+	}
+}
+
+func TestMergePatchOnlyFixesWhatChanges(t *testing.T) {
+	base := "{\"a\": 1 \"b\": [1 2], \"c\": 3}"
+	patch := "{\"c\": 4, \"d\": 5}"
+	// the missing commas of a and b aren't touched
+	expected := "{\"a\": 1 \"b\": [1 2], \"c\": 4, \"d\": 5}"
+
+	merged, err := jsoncomma.MergePatch(&jsoncomma.Config{}, []byte(base), []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(merged) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, merged)
+	}
+}
@@ -0,0 +1,364 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Kind is the type of a Value
+type Kind int
+
+const (
+	Null Kind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Null:
+		return "null"
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Array:
+		return "array"
+	}
+	return "object"
+}
+
+// Comment is a // or /* */ comment
+type Comment struct {
+	// Text is exactly as in the input, including the // or /* */
+	Text string
+	// Start and End are the offsets of the comment in the input
+	Start, End int
+}
+
+// Block returns true for /* */ comments
+func (c Comment) Block() bool {
+	return strings.HasPrefix(c.Text, "/*")
+}
+
+// Lines returns the content of the comment, without the comment markers,
+// split in lines
+func (c Comment) Lines() []string {
+	if !c.Block() {
+		return []string{strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))}
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(c.Text, "/*"), "*/")
+	lines := strings.Split(strings.Trim(text, "\r\n"), "\n")
+	for i, line := range lines {
+		// doc-comment style, where every line starts with a *
+		line = strings.TrimSpace(line)
+		if i > 0 || strings.HasPrefix(line, "*") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// Value is a parsed JSON-like value. It keeps everything needed to write it
+// back close to how it was written: literals are kept as is (so numbers
+// don't lose precision), the members keep their order, and the comments are
+// attached to the values they are next to.
+type Value struct {
+	Kind Kind
+	// Raw is the literal as written for null, bools, numbers and strings
+	// (with the quotes)
+	Raw string
+	// Members of an object, in order
+	Members []*Member
+	// Elements of an array
+	Elements []*Value
+
+	// Comments are the comments right before the value (before the key,
+	// for the value of a member)
+	Comments []Comment
+	// LineComment is a comment following the value on the same line, if any
+	LineComment *Comment
+	// Inner are the comments after the last member/element, before the
+	// closing bracket
+	Inner []Comment
+
+	// Start and End are the offsets of the value in the input (End is
+	// right after the value)
+	Start, End int
+}
+
+// Member is a key and its value
+type Member struct {
+	// Key is decoded (escape sequences are interpreted)
+	Key string
+	// RawKey is the key as written, with the quotes if it had some
+	RawKey string
+	// KeyStart is the offset of the key in the input
+	KeyStart int
+	Value    *Value
+}
+
+// Document is a parsed JSON-like document
+type Document struct {
+	Root *Value
+	// Trailing are the comments after the root value
+	Trailing []Comment
+}
+
+// Str returns the decoded content of a string value
+func (v *Value) Str() (string, error) {
+	return Unquote(v.Raw)
+}
+
+// Lookup returns the member with this key (the last one, if there are
+// duplicates, like encoding/json), or nil
+func (v *Value) Lookup(key string) *Member {
+	for i := len(v.Members) - 1; i >= 0; i-- {
+		if v.Members[i].Key == key {
+			return v.Members[i]
+		}
+	}
+	return nil
+}
+
+// Unquote decodes a double or single quoted string
+func Unquote(raw string) (string, error) {
+	if len(raw) < 2 || (raw[0] != '"' && raw[0] != '\'') || raw[len(raw)-1] != raw[0] {
+		return "", fmt.Errorf("%s isn't a quoted string", raw)
+	}
+	if raw[0] == '\'' {
+		// turn it into a double quoted string: \' isn't an escape sequence
+		// anymore, and " needs one
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := 1; i < len(raw)-1; i++ {
+			switch {
+			case raw[i] == '\\' && raw[i+1] == '\'':
+				b.WriteByte('\'')
+				i++
+			case raw[i] == '\\':
+				b.WriteString(raw[i : i+2])
+				i++
+			case raw[i] == '"':
+				b.WriteString(`\"`)
+			default:
+				b.WriteByte(raw[i])
+			}
+		}
+		b.WriteByte('"')
+		raw = b.String()
+	}
+	var s string
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return "", fmt.Errorf("invalid string %s", raw)
+	}
+	return s, nil
+}
+
+// Parse parses JSON-like content: comments, and missing or extra commas are
+// allowed (the commas are just ignored), as well as single quoted strings
+// and unquoted keys. Errors are *SyntaxError.
+func Parse(content []byte) (*Document, error) {
+	tokens, err := Tokens(content)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{content: content, tokens: tokens}
+
+	comments := p.comments()
+	if p.done() {
+		return nil, p.errorAt(len(content), "no value")
+	}
+	root, err := p.value(comments)
+	if err != nil {
+		return nil, err
+	}
+	root.LineComment = p.lineComment(root.End)
+
+	doc := &Document{Root: root, Trailing: p.comments()}
+	if !p.done() {
+		return nil, p.errorAt(p.peek().Offset, "unexpected %s after the value", p.peek().Kind)
+	}
+	return doc, nil
+}
+
+type parser struct {
+	content []byte
+	tokens  []Token
+	i       int
+}
+
+func (p *parser) done() bool {
+	return p.i == len(p.tokens)
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) errorAt(offset int, format string, args ...interface{}) error {
+	return syntaxError(p.content, offset, format, args...)
+}
+
+// comments consumes the comments at the current position
+func (p *parser) comments() []Comment {
+	var comments []Comment
+	for !p.done() && p.peek().Kind == TokenComment {
+		token := p.peek()
+		comments = append(comments, Comment{Text: token.Text, Start: token.Offset, End: token.End()})
+		p.i++
+	}
+	return comments
+}
+
+// lineComment consumes the comma and the comment following a value which
+// ends at end, if the comment is on the same line
+func (p *parser) lineComment(end int) *Comment {
+	i := p.i
+	if i < len(p.tokens) && p.tokens[i].Kind == TokenComma {
+		i++
+	}
+	if i == len(p.tokens) || p.tokens[i].Kind != TokenComment {
+		return nil
+	}
+	token := p.tokens[i]
+	if bytes.IndexByte(p.content[end:token.Offset], '\n') != -1 {
+		return nil
+	}
+	p.i = i + 1
+	return &Comment{Text: token.Text, Start: token.Offset, End: token.End()}
+}
+
+// skipCommas consumes the commas, and the comments around them
+func (p *parser) skipCommas(comments []Comment) []Comment {
+	for {
+		comments = append(comments, p.comments()...)
+		if p.done() || p.peek().Kind != TokenComma {
+			return comments
+		}
+		p.i++
+	}
+}
+
+func (p *parser) value(comments []Comment) (*Value, error) {
+	if p.done() {
+		return nil, p.errorAt(len(p.content), "unexpected end, expected a value")
+	}
+	token := p.peek()
+	p.i++
+
+	v := &Value{
+		Raw:      token.Text,
+		Comments: comments,
+		Start:    token.Offset,
+		End:      token.End(),
+	}
+
+	switch token.Kind {
+	case TokenString:
+		if _, err := Unquote(token.Text); err != nil {
+			return nil, p.errorAt(token.Offset, "%s", err)
+		}
+		v.Kind = String
+	case TokenNumber:
+		if !json.Valid([]byte(token.Text)) {
+			return nil, p.errorAt(token.Offset, "invalid number %s", token.Text)
+		}
+		v.Kind = Number
+	case TokenLiteral:
+		v.Kind = Bool
+		if token.Text == "null" {
+			v.Kind = Null
+		}
+	case TokenBeginArray:
+		v.Kind = Array
+		v.Raw = ""
+		if err := p.array(v); err != nil {
+			return nil, err
+		}
+	case TokenBeginObject:
+		v.Kind = Object
+		v.Raw = ""
+		if err := p.object(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorAt(token.Offset, "unexpected %s %q, expected a value", token.Kind, token.Text)
+	}
+	return v, nil
+}
+
+func (p *parser) array(v *Value) error {
+	for {
+		comments := p.skipCommas(nil)
+		if p.done() {
+			return p.errorAt(v.Start, "unclosed array")
+		}
+		if p.peek().Kind == TokenEndArray {
+			v.Inner = comments
+			v.End = p.peek().End()
+			p.i++
+			return nil
+		}
+		element, err := p.value(comments)
+		if err != nil {
+			return err
+		}
+		element.LineComment = p.lineComment(element.End)
+		v.Elements = append(v.Elements, element)
+	}
+}
+
+func (p *parser) object(v *Value) error {
+	for {
+		comments := p.skipCommas(nil)
+		if p.done() {
+			return p.errorAt(v.Start, "unclosed object")
+		}
+		token := p.peek()
+		if token.Kind == TokenEndObject {
+			v.Inner = comments
+			v.End = token.End()
+			p.i++
+			return nil
+		}
+
+		member := &Member{RawKey: token.Text, KeyStart: token.Offset}
+		switch token.Kind {
+		case TokenString:
+			key, err := Unquote(token.Text)
+			if err != nil {
+				return p.errorAt(token.Offset, "%s", err)
+			}
+			member.Key = key
+		case TokenIdent, TokenLiteral:
+			member.Key = token.Text
+		default:
+			return p.errorAt(token.Offset, "unexpected %s %q, expected a key", token.Kind, token.Text)
+		}
+		p.i++
+
+		comments = append(comments, p.comments()...)
+		if p.done() || p.peek().Kind != TokenColon {
+			return p.errorAt(token.End(), "expected a colon after the key %s", token.Text)
+		}
+		p.i++
+		comments = append(comments, p.comments()...)
+
+		value, err := p.value(comments)
+		if err != nil {
+			return err
+		}
+		value.LineComment = p.lineComment(value.End)
+		member.Value = value
+		v.Members = append(v.Members, member)
+	}
+}
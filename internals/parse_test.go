@@ -0,0 +1,99 @@
+package jsoncomma_test
+
+import (
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestParse(t *testing.T) {
+	content := []byte("// doc\n{\n\t\"a\": 1.50, // one\n\t/* b */ 'b': [true null],\n\tc: {\"\\u00e9\": \"x\"}\n\t// inner\n}\n// end\n")
+	doc, err := jsoncomma.Parse(content)
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+
+	root := doc.Root
+	if root.Kind != jsoncomma.Object || len(root.Members) != 3 {
+		t.Fatalf("root: expected an object with 3 members, got %s with %d", root.Kind, len(root.Members))
+	}
+	if len(root.Comments) != 1 || root.Comments[0].Text != "// doc" {
+		t.Errorf("root comments: %v", root.Comments)
+	}
+	if len(root.Inner) != 1 || root.Inner[0].Text != "// inner" {
+		t.Errorf("inner comments: %v", root.Inner)
+	}
+	if len(doc.Trailing) != 1 || doc.Trailing[0].Text != "// end" {
+		t.Errorf("trailing comments: %v", doc.Trailing)
+	}
+
+	a := root.Members[0]
+	if a.Key != "a" || a.Value.Raw != "1.50" || a.Value.LineComment == nil || a.Value.LineComment.Text != "// one" {
+		t.Errorf("member a: %+v %+v", a, a.Value)
+	}
+	if got := string(content[a.Value.Start:a.Value.End]); got != "1.50" {
+		t.Errorf("member a: span is %q", got)
+	}
+
+	b := root.Lookup("b")
+	if b == nil || b.RawKey != "'b'" || len(b.Value.Comments) != 1 || b.Value.Comments[0].Lines()[0] != "b" {
+		t.Fatalf("member b: %+v", b)
+	}
+	if len(b.Value.Elements) != 2 || b.Value.Elements[0].Kind != jsoncomma.Bool || b.Value.Elements[1].Kind != jsoncomma.Null {
+		t.Errorf("member b: elements %+v", b.Value.Elements)
+	}
+
+	c := root.Lookup("c")
+	if c == nil || c.Value.Lookup("é") == nil {
+		t.Errorf("member c: %+v", c)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	table := []struct {
+		in   string
+		line int
+		col  int
+	}{
+		{in: ``, line: 1, col: 1},
+		{in: `{"a" 1}`, line: 1, col: 5},
+		{in: "[\n\t1,\n\tnope\n]", line: 3, col: 2},
+		{in: `{"a": 1`, line: 1, col: 1},
+		{in: `[1] [2]`, line: 1, col: 5},
+		{in: `["unterminated]`, line: 1, col: 2},
+		{in: `[01]`, line: 1, col: 2},
+		{in: `{1: 2}`, line: 1, col: 2},
+	}
+
+	for _, row := range table {
+		_, err := jsoncomma.Parse([]byte(row.in))
+		syntaxErr, ok := err.(*jsoncomma.SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected a syntax error, got %v", row.in, err)
+			continue
+		}
+		if syntaxErr.Line != row.line || syntaxErr.Column != row.col {
+			t.Errorf("%q: expected error at %d:%d, got %s", row.in, row.line, row.col, syntaxErr)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	table := []struct {
+		raw string
+		s   string
+	}{
+		{raw: `"hello"`, s: "hello"},
+		{raw: `"tab\t\"quote\""`, s: "tab\t\"quote\""},
+		{raw: `'single "double" \'single\''`, s: `single "double" 'single'`},
+		{raw: `'\u00e9\n'`, s: "é\n"},
+	}
+	for _, row := range table {
+		s, err := jsoncomma.Unquote(row.raw)
+		if err != nil {
+			t.Errorf("%s: %s", row.raw, err)
+		} else if s != row.s {
+			t.Errorf("%s: expected %q, got %q", row.raw, row.s, s)
+		}
+	}
+}
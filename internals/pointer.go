@@ -0,0 +1,382 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits a JSON Pointer (RFC 6901) into its reference tokens
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q: it must be empty or start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.Replace(token, "~1", "/", -1)
+		tokens[i] = strings.Replace(token, "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// location is where a pointer leads to in a document
+type location struct {
+	// parent is the object or array containing the value (nil for the
+	// root)
+	parent *Value
+	// token is the last reference token
+	token string
+	// index is the index of the member or element in the parent, or -1 if
+	// it doesn't exist
+	index int
+	value *Value
+}
+
+// locate finds the value the pointer refers to. Only the last token may
+// refer to something which doesn't exist (index is -1 then).
+func locate(doc *Document, pointer string) (location, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return location{}, err
+	}
+
+	loc := location{value: doc.Root, index: -1}
+	for i, token := range tokens {
+		if loc.value == nil {
+			return loc, fmt.Errorf("%s doesn't exist", describePointer(tokens[:i]))
+		}
+		parent := loc.value
+		loc = location{parent: parent, token: token, index: -1}
+
+		switch parent.Kind {
+		case Object:
+			for j := len(parent.Members) - 1; j >= 0; j-- {
+				if parent.Members[j].Key == token {
+					loc.index = j
+					loc.value = parent.Members[j].Value
+					break
+				}
+			}
+		case Array:
+			if token == "-" {
+				// after the last element
+				continue
+			}
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+				return loc, fmt.Errorf("%s: %q isn't an array index", describePointer(tokens[:i+1]), token)
+			}
+			if index < len(parent.Elements) {
+				loc.index = index
+				loc.value = parent.Elements[index]
+			}
+		default:
+			return loc, fmt.Errorf("%s is a %s, it doesn't contain anything", describePointer(tokens[:i]), parent.Kind)
+		}
+	}
+	return loc, nil
+}
+
+// describePointer is the pointer made of tokens, in messages
+func describePointer(tokens []string) string {
+	if len(tokens) == 0 {
+		return "the root"
+	}
+	return FormatPointer(tokens...)
+}
+
+// FormatPointer joins reference tokens into a JSON Pointer (the opposite
+// of ParsePointer)
+func FormatPointer(tokens ...string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		token = strings.Replace(token, "~", "~0", -1)
+		b.WriteString("/" + strings.Replace(token, "/", "~1", -1))
+	}
+	return b.String()
+}
+
+// Get returns the value the pointer refers to
+func (d *Document) Get(pointer string) (*Value, error) {
+	loc, err := locate(d, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if loc.value == nil {
+		return nil, fmt.Errorf("%s doesn't exist", pointer)
+	}
+	return loc.value, nil
+}
+
+// end returns the offset right after the value and its line comment
+func (v *Value) end() int {
+	if v.LineComment != nil {
+		return v.LineComment.End
+	}
+	return v.End
+}
+
+// item returns the value of the i-th member or element of v
+func (v *Value) item(i int) *Value {
+	if v.Kind == Object {
+		return v.Members[i].Value
+	}
+	return v.Elements[i]
+}
+
+// itemStart returns the offset of the i-th member (its key) or element of v
+func (v *Value) itemStart(i int) int {
+	if v.Kind == Object {
+		return v.Members[i].KeyStart
+	}
+	return v.Elements[i].Start
+}
+
+// leadingStart returns the offset of the i-th member or element of v,
+// including its comments
+func (v *Value) leadingStart(i int) int {
+	if comments := v.item(i).Comments; len(comments) > 0 {
+		return comments[0].Start
+	}
+	return v.itemStart(i)
+}
+
+func (v *Value) items() int {
+	if v.Kind == Object {
+		return len(v.Members)
+	}
+	return len(v.Elements)
+}
+
+// Set sets the value the pointer refers to, editing content in place: only
+// the edited span changes, the rest (comments, whitespace, indentation,
+// commas) is untouched. New members and elements are added at the end,
+// with the indentation of the last one. value is JSON-like.
+func Set(config *Config, content []byte, pointer string, value []byte) ([]byte, error) {
+	return set(config, content, pointer, value, carriedComments{})
+}
+
+// carriedComments are comments to add along with a value (see MergePatch)
+type carriedComments struct {
+	leading []Comment
+	line    *Comment
+}
+
+// commentLines returns the comments as // comments (V1 of the fixer doesn't
+// know block comments)
+func commentLines(comments []Comment) []string {
+	var lines []string
+	for _, comment := range comments {
+		for _, line := range comment.Lines() {
+			lines = append(lines, strings.TrimSpace("// "+line))
+		}
+	}
+	return lines
+}
+
+// lineIndent returns the indentation of the line offset is on, and false if
+// there is something else than whitespace before offset on its line
+func lineIndent(content []byte, offset int) (string, bool) {
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+	indent := content[lineStart:offset]
+	return string(indent), len(bytes.TrimLeft(indent, " \t")) == 0
+}
+
+func set(config *Config, content []byte, pointer string, value []byte, comments carriedComments) ([]byte, error) {
+	value = bytes.TrimSpace(value)
+	if _, err := Parse(value); err != nil {
+		return nil, fmt.Errorf("invalid value: %s", err)
+	}
+
+	doc, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := locate(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc.value != nil {
+		return replace(config, content, loc, value, comments)
+	}
+
+	parent := loc.parent
+	if parent.Kind == Array && loc.token != "-" && loc.token != strconv.Itoa(len(parent.Elements)) {
+		return nil, fmt.Errorf("%s: index out of range (use - to append)", pointer)
+	}
+	item := string(value)
+	if parent.Kind == Object {
+		item = Quote(loc.token, '"') + ": " + item
+	}
+
+	last := parent.items() - 1
+	if last < 0 {
+		// right after the opening bracket
+		return splice(config, content, parent.Start+1, parent.Start+1, parent.Start+1, item)
+	}
+
+	// on the same line as the last item if it's on the same line as the
+	// opening bracket, otherwise on a new line with the same indentation.
+	// The fixer adds the comma. Comments can only be carried in the latter
+	// case.
+	lastStart := parent.itemStart(last)
+	separator := " "
+	if bytes.IndexByte(content[parent.Start:lastStart], '\n') != -1 {
+		separator = "\n"
+		if indent, ok := lineIndent(content, lastStart); ok {
+			separator += indent
+		}
+		for _, line := range commentLines(comments.leading) {
+			item = line + separator + item
+		}
+		if comments.line != nil {
+			item += " " + commentLines([]Comment{*comments.line})[0]
+		}
+	}
+	lastItem := parent.item(last)
+	return splice(config, content, lastItem.End, lastItem.end(), lastItem.end(), separator+item)
+}
+
+// leadingComments returns what to insert (and where) to add the comments
+// before the existing value at loc. It's only possible if the value (or its
+// key) is on its own line. The comments it already has aren't added again.
+func leadingComments(content []byte, loc location, comments []Comment) (int, string) {
+	if loc.parent == nil || len(comments) == 0 {
+		return 0, ""
+	}
+	// after the comments it already has
+	start := loc.parent.itemStart(loc.index)
+	indent, ok := lineIndent(content, start)
+	if !ok {
+		return 0, ""
+	}
+
+	existing := map[string]bool{}
+	for _, line := range commentLines(loc.value.Comments) {
+		existing[line] = true
+	}
+	var text string
+	for _, line := range commentLines(comments) {
+		if !existing[line] {
+			text += line + "\n" + indent
+		}
+	}
+	if text == "" {
+		return 0, ""
+	}
+	return start, text
+}
+
+// replace replaces the existing value at loc. The carried comments are
+// added if the value is on its own line.
+func replace(config *Config, content []byte, loc location, value []byte, comments carriedComments) ([]byte, error) {
+	v := loc.value
+
+	// the line comment goes at the end of the line (after the comma), if
+	// there isn't one already
+	var lineAt int
+	if comments.line != nil && v.LineComment == nil {
+		lineAt = v.End
+		for lineAt < len(content) && (content[lineAt] == ' ' || content[lineAt] == '\t' || content[lineAt] == ',') {
+			lineAt++
+		}
+		if lineAt < len(content) && content[lineAt] != '\n' && content[lineAt] != '\r' {
+			lineAt = 0
+		}
+	}
+
+	leadingAt, leading := leadingComments(content, loc, comments.leading)
+
+	// from the end, so that the offsets stay valid
+	var err error
+	if lineAt != 0 {
+		comment := " " + commentLines([]Comment{*comments.line})[0]
+		if content, err = splice(config, content, lineAt, lineAt, lineAt, comment); err != nil {
+			return nil, err
+		}
+	}
+	if content, err = splice(config, content, v.Start, v.Start, v.End, string(value)); err != nil {
+		return nil, err
+	}
+	if leadingAt != 0 {
+		if content, err = splice(config, content, leadingAt, leadingAt, leadingAt, leading); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// Delete removes the value the pointer refers to (and its comments),
+// editing content in place like Set
+func Delete(config *Config, content []byte, pointer string) ([]byte, error) {
+	doc, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := locate(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if loc.parent == nil {
+		return nil, fmt.Errorf("can't delete the root")
+	}
+	if loc.value == nil {
+		return nil, fmt.Errorf("%s doesn't exist", pointer)
+	}
+
+	// from the end of the previous item, to remove the whitespace before
+	// it, and its comments. The first item is removed up to the next one
+	// instead, so that the next one takes its place.
+	if loc.index == 0 && loc.parent.items() > 1 {
+		start := loc.parent.leadingStart(0)
+		return splice(config, content, start, start, loc.parent.leadingStart(1), "")
+	}
+	from := loc.parent.Start + 1
+	start := from
+	if loc.index > 0 {
+		previous := loc.parent.item(loc.index - 1)
+		from = previous.End
+		start = previous.end()
+	}
+	return splice(config, content, from, start, loc.value.end(), "")
+}
+
+// splice replaces content[start:end] with text, and fixes the commas
+// between from (the end of the value before the replacement) and the first
+// value after it. The commas elsewhere are left alone.
+func splice(config *Config, content []byte, from, start, end int, text string) ([]byte, error) {
+	var edited bytes.Buffer
+	edited.Write(content[:start])
+	edited.WriteString(text)
+	edited.Write(content[end:])
+	result := edited.Bytes()
+
+	tokens, err := Tokens(result)
+	if err != nil {
+		return nil, err
+	}
+	to := len(result)
+	for _, token := range tokens {
+		if token.Offset >= start+len(text) && token.Kind != TokenComma && token.Kind != TokenComment {
+			to = token.Offset
+			break
+		}
+	}
+
+	edits, err := Edits(config, bytes.NewReader(result))
+	if err != nil {
+		return nil, err
+	}
+	var kept []Edit
+	for _, edit := range edits {
+		if edit.Offset >= int64(from) && edit.Offset <= int64(to) {
+			kept = append(kept, edit)
+		}
+	}
+	return Apply(result, kept), nil
+}
@@ -0,0 +1,140 @@
+package jsoncomma_test
+
+import (
+	"reflect"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestParsePointer(t *testing.T) {
+	table := []struct {
+		pointer string
+		tokens  []string
+	}{
+		{pointer: "", tokens: nil},
+		{pointer: "/", tokens: []string{""}},
+		{pointer: "/a/0", tokens: []string{"a", "0"}},
+		{pointer: "/a~1b/m~0n", tokens: []string{"a/b", "m~n"}},
+	}
+	for _, row := range table {
+		tokens, err := jsoncomma.ParsePointer(row.pointer)
+		if err != nil {
+			t.Errorf("%q: %s", row.pointer, err)
+		} else if !reflect.DeepEqual(tokens, row.tokens) {
+			t.Errorf("%q: expected %q, got %q", row.pointer, row.tokens, tokens)
+		}
+		if pointer := jsoncomma.FormatPointer(row.tokens...); pointer != row.pointer {
+			t.Errorf("%q: formatted back as %q", row.tokens, pointer)
+		}
+	}
+	if _, err := jsoncomma.ParsePointer("a"); err == nil {
+		t.Errorf("expected an error for a pointer without a leading /")
+	}
+}
+
+const pointerDoc = `{
+	// the version
+	"version": "1.0.0", // bump me
+	"list": [1 2],
+	"inline": {"a": 1, "b": 2},
+	"keep": [1 2 3]
+}`
+
+func TestSet(t *testing.T) {
+	table := []struct {
+		pointer string
+		value   string
+		out     string
+	}{
+		{
+			pointer: "/version",
+			value:   `"1.1.0"`,
+			out:     "{\n\t// the version\n\t\"version\": \"1.1.0\", // bump me\n\t\"list\": [1 2],\n\t\"inline\": {\"a\": 1, \"b\": 2},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/list/-",
+			value:   `3`,
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1 2, 3],\n\t\"inline\": {\"a\": 1, \"b\": 2},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/inline/c~1d",
+			value:   `null`,
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1 2],\n\t\"inline\": {\"a\": 1, \"b\": 2, \"c/d\": null},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/new",
+			value:   `{"x": [true]}`,
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1 2],\n\t\"inline\": {\"a\": 1, \"b\": 2},\n\t\"keep\": [1 2 3],\n\t\"new\": {\"x\": [true]}\n}",
+		},
+	}
+	for _, row := range table {
+		out, err := jsoncomma.Set(&jsoncomma.Config{}, []byte(pointerDoc), row.pointer, []byte(row.value))
+		if err != nil {
+			t.Errorf("%s: %s", row.pointer, err)
+		} else if string(out) != row.out {
+			t.Errorf("%s: expected\n%s\ngot\n%s", row.pointer, row.out, out)
+		}
+	}
+
+	for _, pointer := range []string{"/list/5", "/version/a", "/nope/a", "version"} {
+		if _, err := jsoncomma.Set(&jsoncomma.Config{}, []byte(pointerDoc), pointer, []byte("1")); err == nil {
+			t.Errorf("%s: expected an error", pointer)
+		}
+	}
+	if _, err := jsoncomma.Set(&jsoncomma.Config{}, []byte(pointerDoc), "/version", []byte("{")); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+
+	// the commas around the new value are placed by the given version
+	versions := map[jsoncomma.Version]string{
+		jsoncomma.V1: "[1 -2]",
+		jsoncomma.V2: "[1, -2]",
+	}
+	for version, expected := range versions {
+		out, err := jsoncomma.Set(&jsoncomma.Config{Version: version}, []byte("[1]"), "/-", []byte("-2"))
+		if err != nil {
+			t.Errorf("%s: %s", version, err)
+		} else if string(out) != expected {
+			t.Errorf("%s: expected %s, got %s", version, expected, out)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	table := []struct {
+		pointer string
+		out     string
+	}{
+		{
+			pointer: "/version",
+			out:     "{\n\t\"list\": [1 2],\n\t\"inline\": {\"a\": 1, \"b\": 2},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/list/1",
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1],\n\t\"inline\": {\"a\": 1, \"b\": 2},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/inline/a",
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1 2],\n\t\"inline\": {\"b\": 2},\n\t\"keep\": [1 2 3]\n}",
+		},
+		{
+			pointer: "/keep",
+			out:     "{\n\t// the version\n\t\"version\": \"1.0.0\", // bump me\n\t\"list\": [1 2],\n\t\"inline\": {\"a\": 1, \"b\": 2}\n}",
+		},
+	}
+	for _, row := range table {
+		out, err := jsoncomma.Delete(&jsoncomma.Config{}, []byte(pointerDoc), row.pointer)
+		if err != nil {
+			t.Errorf("%s: %s", row.pointer, err)
+		} else if string(out) != row.out {
+			t.Errorf("%s: expected\n%s\ngot\n%s", row.pointer, row.out, out)
+		}
+	}
+
+	for _, pointer := range []string{"", "/nope", "/list/-"} {
+		if _, err := jsoncomma.Delete(&jsoncomma.Config{}, []byte(pointerDoc), pointer); err == nil {
+			t.Errorf("%q: expected an error", pointer)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Predicate returns true if the input reproduces a bug
+type Predicate func(input []byte) bool
+
+// Predicates are the bugs Reduce knows how to look for, for the Fix config
+var Predicates = map[string]func(config *Config) Predicate{
+	// the input is JSON-like, but Fix's output isn't valid JSON (ignoring
+	// the comments)
+	"invalid-output": InvalidOutput,
+	// fixing Fix's output changes it
+	"not-idempotent": NotIdempotent,
+	// Fix returns an error, or panics
+	"error": FixFails,
+}
+
+// FixBytes is Fix on a slice, turning panics into errors
+func FixBytes(config *Config, input []byte) (output []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	var out bytes.Buffer
+	_, err = Fix(config, bytes.NewReader(input), &out)
+	return out.Bytes(), err
+}
+
+// FixFails is true if Fix returns an error or panics
+func FixFails(config *Config) Predicate {
+	return func(input []byte) bool {
+		_, err := FixBytes(config, input)
+		return err != nil
+	}
+}
+
+// NotIdempotent is true if fixing the output of Fix changes it
+func NotIdempotent(config *Config) Predicate {
+	return func(input []byte) bool {
+		once, err := FixBytes(config, input)
+		if err != nil {
+			return false
+		}
+		twice, err := FixBytes(config, once)
+		return err == nil && !bytes.Equal(once, twice)
+	}
+}
+
+// InvalidOutput is true if the input is JSON with // comments and wrong
+// commas (which Fix should fix), but Fix's output isn't valid JSON once the
+// comments are removed
+func InvalidOutput(config *Config) Predicate {
+	return func(input []byte) bool {
+		if _, ok := Reference(input); !ok {
+			return false
+		}
+		output, err := FixBytes(config, input)
+		if err != nil {
+			return false
+		}
+		withoutComments, ok := stripComments(output)
+		return ok && !json.Valid(withoutComments)
+	}
+}
+
+// isStrict returns true if the tokens are JSON tokens, or // comments
+func isStrict(tokens []Token) bool {
+	for _, token := range tokens {
+		switch token.Kind {
+		case TokenIdent, TokenInvalid:
+			return false
+		case TokenString:
+			if token.Text[0] != '"' {
+				return false
+			}
+		case TokenComment:
+			if (Comment{Text: token.Text}).Block() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func stripComments(content []byte) ([]byte, bool) {
+	tokens, err := Tokens(content)
+	if err != nil {
+		return nil, false
+	}
+	var out bytes.Buffer
+	pos := 0
+	for _, token := range tokens {
+		if token.Kind == TokenComment {
+			out.Write(content[pos:token.Offset])
+			pos = token.End()
+		}
+	}
+	out.Write(content[pos:])
+	return out.Bytes(), true
+}
+
+// Reference is what Fix should output, computed from the parsed document
+// instead: every comma is removed, and one is added right after each item
+// but the last. It returns false if the input isn't JSON with // comments
+// and wrong commas.
+func Reference(input []byte) ([]byte, bool) {
+	tokens, err := Tokens(input)
+	if err != nil || !isStrict(tokens) {
+		return nil, false
+	}
+	doc, err := Parse(input)
+	if err != nil {
+		return nil, false
+	}
+
+	var commas []int
+	var visit func(v *Value)
+	visit = func(v *Value) {
+		for i := 0; i < v.items(); i++ {
+			item := v.item(i)
+			if i < v.items()-1 {
+				commas = append(commas, item.End)
+			}
+			visit(item)
+		}
+	}
+	visit(doc.Root)
+
+	var edits []Edit
+	isComma := map[int]bool{}
+	for _, token := range tokens {
+		if token.Kind == TokenComma {
+			isComma[token.Offset] = true
+		}
+	}
+	needed := map[int]bool{}
+	for _, offset := range commas {
+		needed[offset] = true
+	}
+	for offset := 0; offset <= len(input); offset++ {
+		if needed[offset] {
+			edits = append(edits, Edit{Kind: Insert, Offset: int64(offset)})
+		}
+		if isComma[offset] {
+			edits = append(edits, Edit{Kind: Remove, Offset: int64(offset)})
+		}
+	}
+	return Apply(input, edits), true
+}
+
+// Reduce shrinks the input while the predicate is still true for it, first
+// line by line, then byte by byte (with the ddmin algorithm). The predicate
+// must be true for the input.
+func Reduce(input []byte, predicate Predicate) []byte {
+	lines := bytes.SplitAfter(input, []byte("\n"))
+	input = bytes.Join(ddmin(lines, predicate), nil)
+
+	units := make([][]byte, len(input))
+	for i := range input {
+		units[i] = input[i : i+1]
+	}
+	return bytes.Join(ddmin(units, predicate), nil)
+}
+
+// ddmin returns a subset of the units (in order) for which the predicate
+// is still true, and for which removing any unit makes it false
+func ddmin(units [][]byte, predicate Predicate) [][]byte {
+	test := func(units [][]byte) bool {
+		return predicate(bytes.Join(units, nil))
+	}
+
+	n := 2
+	for len(units) >= 2 {
+		chunks := split(units, n)
+		reduced := false
+
+		for _, chunk := range chunks {
+			if test(chunk) {
+				units, n, reduced = chunk, 2, true
+				break
+			}
+		}
+		// with 2 chunks, the complements are the chunks
+		if !reduced && n > 2 {
+			for i := range chunks {
+				complement := complementOf(chunks, i)
+				if test(complement) {
+					units, reduced = complement, true
+					if n--; n < 2 {
+						n = 2
+					}
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n >= len(units) {
+				break
+			}
+			n *= 2
+			if n > len(units) {
+				n = len(units)
+			}
+		}
+	}
+	return units
+}
+
+// split splits the units in n chunks of about the same size
+func split(units [][]byte, n int) [][][]byte {
+	chunks := make([][][]byte, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(units)-start)/(n-i)
+		chunks = append(chunks, units[start:end])
+		start = end
+	}
+	return chunks
+}
+
+func complementOf(chunks [][][]byte, skip int) [][]byte {
+	var complement [][]byte
+	for i, chunk := range chunks {
+		if i != skip {
+			complement = append(complement, chunk...)
+		}
+	}
+	return complement
+}
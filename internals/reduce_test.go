@@ -0,0 +1,52 @@
+package jsoncomma_test
+
+import (
+	"bytes"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestReference(t *testing.T) {
+	table := []struct {
+		in, out string
+		ok      bool
+	}{
+		{in: `[1 2 3]`, out: `[1, 2, 3]`, ok: true},
+		{in: `[1,, 2,]`, out: `[1, 2]`, ok: true},
+		{in: "{\"a\": 1 // one\n\"b\": [] ,}", out: "{\"a\": 1, // one\n\"b\": [] }", ok: true},
+		{in: `[1 -2]`, out: `[1, -2]`, ok: true},
+		{in: `{'a': 1}`, ok: false},
+		{in: `[1 /* one */ 2]`, ok: false},
+		{in: `[1`, ok: false},
+	}
+
+	for _, row := range table {
+		out, ok := jsoncomma.Reference([]byte(row.in))
+		if ok != row.ok || (ok && string(out) != row.out) {
+			t.Errorf("%#q: expected %#q (%t), got %#q (%t)", row.in, row.out, row.ok, out, ok)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	// any input containing both a and b
+	predicate := func(input []byte) bool {
+		return bytes.Contains(input, []byte("a")) && bytes.Contains(input, []byte("b"))
+	}
+	reduced := jsoncomma.Reduce([]byte("xxxxxx\nxxaxx\nxxx\nxbx\nxxxxx\n"), predicate)
+	if string(reduced) != "ab" {
+		t.Errorf("expected %q, got %q", "ab", reduced)
+	}
+
+	// v1 doesn't know that - starts a number
+	invalidOutput := jsoncomma.InvalidOutput(&jsoncomma.Config{Version: jsoncomma.V1})
+	input := []byte("{\n\t\"name\": \"jsoncomma\"\n\t\"numbers\": [1, 2, -3, 4]\n\t\"ok\": true\n}\n")
+	if !invalidOutput(input) {
+		t.Fatalf("expected %#q to give an invalid output", input)
+	}
+	reduced = jsoncomma.Reduce(input, invalidOutput)
+	if !invalidOutput(reduced) || len(reduced) > 12 {
+		t.Errorf("expected a small input giving an invalid output, got %#q", reduced)
+	}
+}
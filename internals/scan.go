@@ -0,0 +1,226 @@
+package jsoncomma
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// TokenKind is the kind of a Token
+type TokenKind int
+
+const (
+	TokenBeginObject TokenKind = iota
+	TokenEndObject
+	TokenBeginArray
+	TokenEndArray
+	TokenColon
+	TokenComma
+	// TokenString is a double quoted string, or a single quoted one (JSON5)
+	TokenString
+	TokenNumber
+	// TokenLiteral is true, false or null
+	TokenLiteral
+	// TokenIdent is any other bare word (unquoted JSON5 keys, typos, etc)
+	TokenIdent
+	// TokenComment is a // or a /* */ comment
+	TokenComment
+	// TokenInvalid is a character which can't start any token
+	TokenInvalid
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenBeginObject:
+		return "{"
+	case TokenEndObject:
+		return "}"
+	case TokenBeginArray:
+		return "["
+	case TokenEndArray:
+		return "]"
+	case TokenColon:
+		return ":"
+	case TokenComma:
+		return ","
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenLiteral:
+		return "literal"
+	case TokenIdent:
+		return "identifier"
+	case TokenComment:
+		return "comment"
+	}
+	return "invalid character"
+}
+
+// Token is a piece of JSON-like content, whitespace excluded
+type Token struct {
+	Kind TokenKind
+	// Text is exactly as in the input (strings include their quotes)
+	Text string
+	// Offset is the position of the first byte in the input
+	Offset int
+}
+
+// End is the offset right after the token
+func (t Token) End() int {
+	return t.Offset + len(t.Text)
+}
+
+// SyntaxError is an error at a position of the input
+type SyntaxError struct {
+	Msg    string
+	Offset int
+	// Line and Column start at 1. The column is in bytes.
+	Line   int
+	Column int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+func syntaxError(content []byte, offset int, format string, args ...interface{}) *SyntaxError {
+	line, column := Position(content, offset)
+	return &SyntaxError{
+		Msg:    fmt.Sprintf(format, args...),
+		Offset: offset,
+		Line:   line,
+		Column: column,
+	}
+}
+
+// Position converts an offset into a line and a column (both starting at 1)
+func Position(content []byte, offset int) (line, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	before := content[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
+
+// Scanner splits JSON-like content into tokens. It scans the same things
+// the Fixer understands (strings, comments, values and punctuation), but
+// keeps track of where they are.
+type Scanner struct {
+	content []byte
+	offset  int
+}
+
+// NewScanner returns a scanner reading content
+func NewScanner(content []byte) *Scanner {
+	return &Scanner{content: content}
+}
+
+// Next returns the next token, skipping whitespace. It returns io.EOF at the
+// end of the content, and a *SyntaxError for unterminated strings and
+// comments.
+func (s *Scanner) Next() (Token, error) {
+	content := s.content
+	for s.offset < len(content) && isSpace(content[s.offset]) {
+		s.offset++
+	}
+	if s.offset == len(content) {
+		return Token{}, io.EOF
+	}
+
+	start := s.offset
+	b := content[start]
+	kind := TokenInvalid
+	end := start + 1
+
+	switch {
+	case b == '{':
+		kind = TokenBeginObject
+	case b == '}':
+		kind = TokenEndObject
+	case b == '[':
+		kind = TokenBeginArray
+	case b == ']':
+		kind = TokenEndArray
+	case b == ':':
+		kind = TokenColon
+	case b == ',':
+		kind = TokenComma
+
+	case b == '/' && start+1 < len(content) && content[start+1] == '/':
+		kind = TokenComment
+		end = bytes.IndexByte(content[start:], '\n')
+		if end == -1 {
+			end = len(content)
+		} else {
+			end += start
+			if end > start && content[end-1] == '\r' {
+				end--
+			}
+		}
+
+	case b == '/' && start+1 < len(content) && content[start+1] == '*':
+		kind = TokenComment
+		end = bytes.Index(content[start+2:], []byte("*/"))
+		if end == -1 {
+			return Token{}, syntaxError(content, start, "unterminated block comment")
+		}
+		end += start + 4
+
+	case b == '"' || b == '\'':
+		kind = TokenString
+		end = stringEnd(content, start)
+		if end == -1 {
+			return Token{}, syntaxError(content, start, "unterminated string")
+		}
+
+	case b == '-' || (b >= '0' && b <= '9'):
+		kind = TokenNumber
+		end = numberEnd(content, start)
+
+	case isLetter(b):
+		for end < len(content) && (isLetter(content[end]) || (content[end] >= '0' && content[end] <= '9')) {
+			end++
+		}
+		switch string(content[start:end]) {
+		case "true", "false", "null":
+			kind = TokenLiteral
+		default:
+			kind = TokenIdent
+		}
+
+	default:
+		_, size := utf8.DecodeRune(content[start:])
+		end = start + size
+	}
+
+	s.offset = end
+	return Token{
+		Kind:   kind,
+		Text:   string(content[start:end]),
+		Offset: start,
+	}, nil
+}
+
+// Tokens scans all of content
+func Tokens(content []byte) ([]Token, error) {
+	var tokens []Token
+	scanner := NewScanner(content)
+	for {
+		token, err := scanner.Next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == '\v'
+}
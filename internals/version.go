@@ -0,0 +1,69 @@
+package jsoncomma
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version selects the heuristics Fix uses to place the commas. A given
+// version always gives the same output, so pinning one keeps the output
+// stable when jsoncomma is upgraded.
+type Version int
+
+const (
+	// Default is the version used when none is selected. It always stands
+	// for V1: newer heuristics are only used by those who ask for them, so
+	// upgrading jsoncomma never changes the output on its own.
+	Default Version = iota
+	// V1 is the original behaviour
+	V1
+	// V2 knows that - starts a number (so [1 -2] becomes [1, -2]), doesn't
+	// touch the content of block comments, and considers that a comment
+	// separates values like a space
+	V2
+)
+
+// DefaultVersion is the version Default stands for. It never changes.
+const DefaultVersion = V1
+
+// LatestVersion is the most recent version, which "latest" selects
+const LatestVersion = V2
+
+// Versions lists the versions which can be selected, oldest first
+var Versions = []Version{V1, V2}
+
+func (v Version) String() string {
+	if v == Default {
+		return "default"
+	}
+	return fmt.Sprintf("v%d", int(v))
+}
+
+// Resolve returns the actual version (what Default stands for, for Default)
+func (v Version) Resolve() Version {
+	if v == Default {
+		return DefaultVersion
+	}
+	return v
+}
+
+// ParseVersion parses versions like v1, or latest for the most recent one
+func ParseVersion(s string) (Version, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "default":
+		return Default, nil
+	case "latest":
+		return LatestVersion, nil
+	}
+	for _, v := range Versions {
+		if s == v.String() || "v"+s == v.String() {
+			return v, nil
+		}
+	}
+	names := make([]string, len(Versions))
+	for i, v := range Versions {
+		names[i] = v.String()
+	}
+	return Default, fmt.Errorf("unknown version %q, expected latest or one of %s", s, strings.Join(names, ", "))
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the error codes of JSON-RPC 2.0 (and LSP, for the cancellation)
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcCancelled      = -32800
+)
+
+// rpcError is the error of a JSON-RPC response. Handlers can return one to
+// choose the code, other errors are internal errors.
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcConn reads and writes JSON-RPC 2.0 messages, each preceded by a
+// Content-Length header (like the Language Server Protocol)
+type rpcConn struct {
+	in *bufio.Reader
+
+	// writes can come from several goroutines
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newRPCConn(in io.Reader, out io.Writer) *rpcConn {
+	return &rpcConn{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// read returns the content of the next message, or io.EOF if the input ends
+// between messages
+func (c *rpcConn) read() ([]byte, error) {
+	length := -1
+	headers := 0
+	for {
+		line, err := c.in.ReadString('\n')
+		if err == io.EOF && line == "" && headers == 0 {
+			return nil, io.EOF
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		headers++
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:colon]), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(c.in, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// write writes the message as JSON
+func (c *rpcConn) write(message interface{}) error {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(message); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", content.Len()); err != nil {
+		return err
+	}
+	_, err := c.out.Write(content.Bytes())
+	return err
+}
+
+// rpcRequest is a request, or a notification if it doesn't have an id
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcHandler handles a request. ctx is cancelled if the client cancels the
+// request ($/cancelRequest), or if it goes away.
+type rpcHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// rpcServer dispatches the messages read from conn. Requests are handled
+// concurrently, notifications in order (before the next message is read).
+type rpcServer struct {
+	conn          *rpcConn
+	requests      map[string]rpcHandler
+	notifications map[string]func(params json.RawMessage)
+	// inline are the requests handled before the next message is read (like
+	// shutdown, so that nothing is handled after it)
+	inline map[string]bool
+
+	mu sync.Mutex
+	// cancels are the requests in flight, by id
+	cancels map[string]context.CancelFunc
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newRPCServer(conn *rpcConn) *rpcServer {
+	return &rpcServer{
+		conn:          conn,
+		requests:      map[string]rpcHandler{},
+		notifications: map[string]func(params json.RawMessage){},
+		inline:        map[string]bool{},
+		cancels:       map[string]context.CancelFunc{},
+		stopped:       make(chan struct{}),
+	}
+}
+
+// stop makes serve return, once the requests in flight are done
+func (s *rpcServer) stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+// notify sends a notification to the client
+func (s *rpcServer) notify(method string, params interface{}) error {
+	return s.conn.write(kv{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// serve handles the messages until stop is called, or the input ends. The
+// requests in flight then get shutdownTimeout to finish (none if the input
+// ended, there's no one to respond to anymore), and are cancelled after.
+func (s *rpcServer) serve() error {
+	messages := make(chan []byte)
+	readerr := make(chan error, 1)
+	go func() {
+		for {
+			content, err := s.conn.read()
+			if err != nil {
+				readerr <- err
+				return
+			}
+			select {
+			case messages <- content:
+			case <-s.stopped:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var err error
+	timeout := shutdownTimeout
+loop:
+	for {
+		select {
+		case content := <-messages:
+			s.handle(content, &wg)
+			select {
+			case <-s.stopped:
+				// the message stopped the server
+				break loop
+			default:
+			}
+		case err = <-readerr:
+			timeout = 0
+			break loop
+		case <-s.stopped:
+			break loop
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.cancelAll()
+		<-done
+	}
+
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func (s *rpcServer) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+}
+
+// idKey is the key of the request id in cancels
+func idKey(id json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, id); err != nil {
+		return string(id)
+	}
+	return compact.String()
+}
+
+func (s *rpcServer) handle(content []byte, wg *sync.WaitGroup) {
+	null := json.RawMessage("null")
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.respond(null, nil, &rpcError{Code: rpcInvalidRequest, Message: "batches aren't supported"})
+		return
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(content, &req); err != nil {
+		s.respond(null, nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = null
+		}
+		s.respond(id, nil, &rpcError{Code: rpcInvalidRequest, Message: `expected a "jsonrpc": "2.0" request, with a method`})
+		return
+	}
+
+	if req.ID == nil {
+		if req.Method == "$/cancelRequest" {
+			var params struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if err := json.Unmarshal(req.Params, &params); err == nil {
+				s.mu.Lock()
+				if cancel, ok := s.cancels[idKey(params.ID)]; ok {
+					cancel()
+				}
+				s.mu.Unlock()
+			}
+			return
+		}
+		// unknown notifications are ignored, as the protocol says
+		if notification, ok := s.notifications[req.Method]; ok {
+			notification(req.Params)
+		}
+		return
+	}
+
+	handler, ok := s.requests[req.Method]
+	if !ok {
+		s.respond(req.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	key := idKey(req.ID)
+	s.mu.Lock()
+	s.cancels[key] = cancel
+	s.mu.Unlock()
+
+	run := func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, key)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := handler(ctx, req.Params)
+		if err != nil && ctx.Err() != nil {
+			err = &rpcError{Code: rpcCancelled, Message: "request cancelled"}
+		}
+		s.respond(req.ID, result, err)
+	}
+	if s.inline[req.Method] {
+		run()
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		run()
+	}()
+}
+
+func (s *rpcServer) respond(id json.RawMessage, result interface{}, err error) {
+	response := kv{
+		"jsonrpc": "2.0",
+		"id":      id,
+	}
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	if err := s.conn.write(response); err != nil {
+		log.Printf("writing response: %s", err)
+	}
+}
+
+// unmarshalParams decodes the params, with an invalid params error
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
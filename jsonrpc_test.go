@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func frame(message string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(message), message)
+}
+
+// readResponses reads all the messages, by id
+func readResponses(t *testing.T, out []byte) map[string]kv {
+	conn := newRPCConn(bytes.NewReader(out), nil)
+	responses := map[string]kv{}
+	for {
+		content, err := conn.read()
+		if err == io.EOF {
+			return responses
+		}
+		if err != nil {
+			t.Fatalf("reading the responses: %s", err)
+		}
+		var response kv
+		if err := json.Unmarshal(content, &response); err != nil {
+			t.Fatalf("invalid response %s: %s", content, err)
+		}
+		responses[fmt.Sprint(response["id"])] = response
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	in := strings.Join([]string{
+		frame(`{"jsonrpc": "2.0", "id": 1, "method": "fix", "params": {"text": "[1 2 -3,]"}}`),
+		frame(`{"jsonrpc": "2.0", "id": 2, "method": "fix", "params": {"text": "[1 2 -3,]", "compat": "v2"}}`),
+		frame(`{"jsonrpc": "2.0", "id": 3, "method": "check", "params": {"text": "[1, 2]"}}`),
+		frame(`{"jsonrpc": "2.0", "id": 4, "method": "fix", "params": {"text": 1}}`),
+		frame(`{"jsonrpc": "2.0", "id": 5, "method": "format"}`),
+		frame(`{"jsonrpc": "2.0", "id": 6, "method": "version"}`),
+		frame(`{"jsonrpc": "2.0", "id": 7, "method": "fix", "params": {"text": "[]", "compat": "v0"}}`),
+		frame(`{"jsonrpc": "2.0", "id": 8, "method": "shutdown"}`),
+		frame(`{"jsonrpc": "2.0", "id": 9, "method": "version"}`),
+	}, "")
+
+	var out bytes.Buffer
+	if err := serveStdio(strings.NewReader(in), &out, 0); err != nil {
+		t.Fatal(err)
+	}
+	responses := readResponses(t, out.Bytes())
+
+	expected := map[string]string{
+		// the default is v1
+		"1": `{"id":1,"jsonrpc":"2.0","result":{"inserted":1,"removed":1,"text":"[1, 2 -3]"}}`,
+		"2": `{"id":2,"jsonrpc":"2.0","result":{"inserted":2,"removed":1,"text":"[1, 2, -3]"}}`,
+		"3": `{"id":3,"jsonrpc":"2.0","result":{"fixed":true,"inserted":0,"removed":0}}`,
+		"8": `{"id":8,"jsonrpc":"2.0","result":null}`,
+	}
+	for id, response := range expected {
+		actual, _ := json.Marshal(responses[id])
+		if string(actual) != response {
+			t.Errorf("id %s:\nactual:   %s\nexpected: %s", id, actual, response)
+		}
+	}
+	codes := map[string]float64{"4": rpcInvalidParams, "5": rpcMethodNotFound, "7": rpcInvalidParams}
+	for id, code := range codes {
+		rpcErr, _ := responses[id]["error"].(map[string]interface{})
+		if rpcErr == nil || rpcErr["code"] != code {
+			t.Errorf("id %s: expected the error %v, got %v", id, code, responses[id])
+		}
+	}
+	if _, ok := responses["6"]["result"].(map[string]interface{})["versions"]; !ok {
+		t.Errorf("id 6: expected the versions, got %v", responses["6"])
+	}
+	if _, ok := responses["9"]; ok {
+		t.Errorf("expected no response after the shutdown, got %v", responses["9"])
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	in := frame(`{"jsonrpc": "2.0", "id": 1`) +
+		frame(`[{"jsonrpc": "2.0", "id": 2, "method": "version"}]`) +
+		frame(`{"id": 3, "method": "version"}`)
+	var out bytes.Buffer
+	if err := newRPCServer(newRPCConn(strings.NewReader(in), &out)).serve(); err != nil {
+		t.Fatal(err)
+	}
+	conn := newRPCConn(&out, nil)
+	for _, code := range []float64{rpcParseError, rpcInvalidRequest, rpcInvalidRequest} {
+		content, err := conn.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Error struct {
+				Code float64
+			}
+		}
+		json.Unmarshal(content, &response)
+		if response.Error.Code != code {
+			t.Errorf("expected the error %v, got %s", code, content)
+		}
+	}
+
+	if _, err := newRPCConn(strings.NewReader("Content-Type: json\r\n\r\n{}"), nil).read(); err == nil {
+		t.Errorf("expected an error without a Content-Length")
+	}
+	if _, err := newRPCConn(strings.NewReader("Content-Length: 10\r\n\r\n{}"), nil).read(); err == nil {
+		t.Errorf("expected an error with a truncated message")
+	}
+}
+
+func TestRPCCancel(t *testing.T) {
+	inr, inw := io.Pipe()
+	outr, outw := io.Pipe()
+	s := newRPCServer(newRPCConn(inr, outw))
+	started := make(chan struct{})
+	s.requests["wait"] = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serve()
+	}()
+
+	io.WriteString(inw, frame(`{"jsonrpc": "2.0", "id": "w", "method": "wait"}`))
+	<-started
+	io.WriteString(inw, frame(`{"jsonrpc": "2.0", "method": "$/cancelRequest", "params": {"id": "w"}}`))
+
+	content, err := newRPCConn(outr, nil).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf(`{"error":{"code":%d,"message":"request cancelled"},"id":"w","jsonrpc":"2.0"}`, rpcCancelled)
+	if string(bytes.TrimSpace(content)) != expected {
+		t.Errorf("actual:   %s\nexpected: %s", content, expected)
+	}
+
+	inw.Close()
+	if err := <-served; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var lintCmd = &command{
+	name:  "lint",
+	args:  "files...",
+	short: "Checks files for common mistakes (commas, duplicate keys, lost precision, etc)",
+	long:  "Exits with status 1 if there are errors, 2 if a file can't be linted.",
+	flags: flag.NewFlagSet("lint", flag.ExitOnError),
+	files: true,
+}
+
+var lintRules = lintCmd.flags.String("rules", "", "JSON-like `file` configuring the rules, like\n"+
+	"{\"json5\": true, \"rules\": {\"max-depth\": {\"severity\": \"error\", \"max\": 5}, \"empty-keys\": \"off\"}}\n"+
+	"(rules: "+strings.Join(jsoncomma.Rules, ", ")+")")
+var lintJSON5 = lintCmd.flags.Bool("json5", false, "allow single quoted strings and unquoted keys")
+var lintFix = lintCmd.flags.Bool("fix", false, "apply the safe autofixes in place, and report what's left")
+var lintJSON = lintCmd.flags.Bool("json", false, "print a JSON object per problem")
+var lintInput = addInputFlags(lintCmd.flags)
+var lintCompat = addCompatFlag(lintCmd.flags)
+
+func init() {
+	lintCmd.run = runLint
+}
+
+// lintRulesFile is the content of the -rules file. Each rule is either a
+// severity, or an object with a severity and the rule's options.
+type lintRulesFile struct {
+	JSON5 *bool                      `json:"json5"`
+	Rules map[string]json.RawMessage `json:"rules"`
+}
+
+func loadLintConfig(filename string) (jsoncomma.LintConfig, error) {
+	config := jsoncomma.DefaultLintConfig()
+	config.JSON5 = *lintJSON5
+	config.Version = *lintCompat
+	if filename == "" {
+		return config, nil
+	}
+
+	doc, err := loadDocument(filename, filename, *lintCompat)
+	if err != nil {
+		return config, err
+	}
+	content, err := emitJSON(doc)
+	if err != nil {
+		return config, err
+	}
+	var file lintRulesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return config, fmt.Errorf("%s: %s", filename, err)
+	}
+	if file.JSON5 != nil && !*lintJSON5 {
+		config.JSON5 = *file.JSON5
+	}
+
+	for name, raw := range file.Rules {
+		rule, ok := config.Rules[name]
+		if !ok {
+			return config, fmt.Errorf("%s: unknown rule %q (rules: %s)", filename, name, strings.Join(jsoncomma.Rules, ", "))
+		}
+
+		var options struct {
+			Severity string `json:"severity"`
+			Max      *int   `json:"max"`
+			Style    string `json:"style"`
+		}
+		if err := json.Unmarshal(raw, &options.Severity); err != nil {
+			if err := json.Unmarshal(raw, &options); err != nil {
+				return config, fmt.Errorf("%s: rule %q: expected a severity or an object", filename, name)
+			}
+		}
+		if options.Severity != "" {
+			if rule.Severity, err = jsoncomma.ParseSeverity(options.Severity); err != nil {
+				return config, fmt.Errorf("%s: rule %q: %s", filename, name, err)
+			}
+		}
+		if options.Max != nil {
+			rule.Max = *options.Max
+		}
+		switch options.Style {
+		case "":
+		case "double", "single", "consistent":
+			rule.Style = options.Style
+		default:
+			return config, fmt.Errorf("%s: rule %q: unknown style %q, expected double, single or consistent", filename, name, options.Style)
+		}
+		config.Rules[name] = rule
+	}
+	return config, nil
+}
+
+func runLint(args []string) error {
+	config, err := loadLintConfig(*lintRules)
+	if err != nil {
+		return err
+	}
+
+	filenames, err := lintInput.resolve(args)
+	if err != nil {
+		return err
+	}
+	if len(filenames) == 0 {
+		lintCmd.flags.Usage()
+		return exitStatus(2)
+	}
+
+	// with -fix, stdin is fixed to stdout, so the problems go to stderr
+	var out io.Writer = os.Stdout
+	for _, filename := range filenames {
+		if *lintFix && filename == stdinArg {
+			out = os.Stderr
+		}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	failed := false
+	hasErrors := false
+	for _, filename := range filenames {
+		name := lintInput.displayName(filename)
+		problems, err := lintFile(filename, name, config)
+		if err != nil {
+			log.Printf("linting %q: %s", name, err)
+			failed = true
+			continue
+		}
+
+		for _, problem := range problems {
+			if problem.Severity == jsoncomma.Error {
+				hasErrors = true
+			}
+			if *lintJSON {
+				if err := encoder.Encode(kv{
+					"file":     name,
+					"line":     problem.Line,
+					"column":   problem.Column,
+					"rule":     problem.Rule,
+					"severity": problem.Severity.String(),
+					"message":  problem.Message,
+					"fixable":  len(problem.Fix) > 0,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(out, "%s:%d:%d: %s: %s (%s)\n", name, problem.Line, problem.Column, problem.Severity, problem.Message, problem.Rule)
+		}
+	}
+
+	if failed {
+		return exitStatus(2)
+	}
+	if hasErrors {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// lintFile lints the file, and autofixes it with -fix (stdin is fixed to
+// stdout). It returns the problems which are left.
+func lintFile(filename, name string, config jsoncomma.LintConfig) ([]jsoncomma.Problem, error) {
+	content, err := readInput(filename, name)
+	if err != nil {
+		return nil, err
+	}
+	if !*lintFix {
+		return jsoncomma.Lint(content, config)
+	}
+
+	fixed, problems, err := jsoncomma.Autofix(content, config)
+	if err != nil {
+		return nil, err
+	}
+	if filename == stdinArg {
+		if _, err := os.Stdout.Write(fixed); err != nil {
+			return nil, err
+		}
+	} else if !bytes.Equal(fixed, content) {
+		if err := writeInPlace(filename, fixed); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
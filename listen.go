@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// listen opens the socket the server listens on: TCP, or a unix socket
+func listen(opts serverOptions) (net.Listener, error) {
+	if opts.unix == "" {
+		return net.Listen("tcp", fmt.Sprintf("%s:%d", opts.host, opts.port))
+	}
+	if strings.HasPrefix(opts.unix, "@") {
+		// abstract sockets have no file, so no permissions either
+		return net.Listen("unix", opts.unix)
+	}
+
+	if err := removeStaleSocket(opts.unix); err != nil {
+		return nil, err
+	}
+	// the socket is created in a private directory, and only moved to its
+	// path once it is 0600, so that no one else can connect in between
+	dir, err := ioutil.TempDir(filepath.Dir(opts.unix), ".jsoncomma")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "server.sock")
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed by Close, at its final path
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(private, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// unlike a rename, a link doesn't replace a socket another server
+	// created in the meantime
+	if err := os.Link(private, opts.unix); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unixListener{listener.(*net.UnixListener), opts.unix}, nil
+}
+
+// unixListener removes the socket file when it is closed
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// removeStaleSocket removes the socket at path if no one is listening on it
+// anymore (a server which crashed). It refuses to remove anything else.
+func removeStaleSocket(path string) error {
+	stat, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s already exists, and isn't a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use: another server is listening on it", path)
+	}
+	return os.Remove(path)
+}
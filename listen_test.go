@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets have no permissions on windows")
+	}
+	dir, err := ioutil.TempDir("", "jsoncomma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.sock")
+
+	listener, err := listen(serverOptions{unix: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket to be 0600, got %s", stat.Mode().Perm())
+	}
+	// only the socket is left in the directory
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("expected only the socket in %s, got %v (%v)", dir, entries, err)
+	}
+
+	if _, err := listen(serverOptions{unix: path}); err == nil {
+		t.Errorf("expected an error, the socket is in use")
+	}
+	listener.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed on close, got %v", err)
+	}
+
+	// a stale socket, left by a server which crashed
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	listener, err = listen(serverOptions{unix: path})
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced: %s", err)
+	}
+	listener.Close()
+
+	notSocket := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(notSocket, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(serverOptions{unix: notSocket}); err == nil {
+		t.Errorf("expected an error, the file isn't a socket")
+	}
+}
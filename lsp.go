@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var lspCmd = &command{
+	name:  "lsp",
+	short: "Runs a language server reporting and fixing the commas, on stdin and stdout",
+	long:  "Any editor with an LSP client can use it.",
+	flags: flag.NewFlagSet("lsp", flag.ExitOnError),
+}
+
+var lspCompat = addCompatFlag(lspCmd.flags)
+
+func init() {
+	lspCmd.run = func(args []string) error {
+		if len(args) != 0 {
+			lspCmd.flags.Usage()
+			return exitStatus(2)
+		}
+		return serveLSP(os.Stdin, os.Stdout, *lspCompat)
+	}
+}
+
+// the LSP constants used below
+const (
+	lspSyncIncremental  = 2
+	lspSeverityError    = 1
+	lspSeverityWarning  = 2
+	lspServerNotStarted = -32002
+)
+
+// lspPosition is a position in a document. Character counts UTF-16 code
+// units, the only encoding every client supports.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+// lspDocument is an open document, with the start of each line to convert
+// offsets to positions
+type lspDocument struct {
+	uri     string
+	version int
+	text    string
+	lines   []int
+}
+
+func newLSPDocument(uri string, version int, text string) *lspDocument {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		// lines end with \n, \r\n or \r
+		if text[i] == '\n' || (text[i] == '\r' && (i+1 == len(text) || text[i+1] != '\n')) {
+			lines = append(lines, i+1)
+		}
+	}
+	return &lspDocument{uri: uri, version: version, text: text, lines: lines}
+}
+
+// position converts a byte offset into a position
+func (d *lspDocument) position(offset int) lspPosition {
+	line := sort.SearchInts(d.lines, offset+1) - 1
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character++
+		if r >= 0x10000 {
+			// a surrogate pair
+			character++
+		}
+	}
+	return lspPosition{Line: line, Character: character}
+}
+
+// offset converts a position into a byte offset. Positions past the end of
+// a line are the end of the line, as the protocol says.
+func (d *lspDocument) offset(pos lspPosition) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	end := len(d.text)
+	if pos.Line+1 < len(d.lines) {
+		end = d.lines[pos.Line+1]
+	}
+	end -= len(d.text[offset:end]) - len(strings.TrimRight(d.text[offset:end], "\r\n"))
+	for character := 0; character < pos.Character && offset < end; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		character++
+		if r >= 0x10000 {
+			character++
+		}
+		offset += size
+	}
+	return offset
+}
+
+func (d *lspDocument) rangeOf(start, end int) lspRange {
+	return lspRange{Start: d.position(start), End: d.position(end)}
+}
+
+// commaFix is an edit of the fixer, as the LSP sees it
+type commaFix struct {
+	edit       jsoncomma.Edit
+	diagnostic lspDiagnostic
+	textEdit   lspTextEdit
+	title      string
+}
+
+// fixes returns the edits the fixer would make to the document
+func (d *lspDocument) fixes(ctx context.Context, config *jsoncomma.Config) ([]commaFix, error) {
+	edits, err := jsoncomma.Edits(config, contextReader{ctx, strings.NewReader(d.text)})
+	if err != nil {
+		return nil, err
+	}
+	// the tokens are only used to tell trailing commas apart, the ones
+	// before an error are enough
+	tokens, _ := jsoncomma.Tokens([]byte(d.text))
+	fixes := make([]commaFix, len(edits))
+	for i, edit := range edits {
+		offset := int(edit.Offset)
+		fix := commaFix{edit: edit}
+		if edit.Kind == jsoncomma.Insert {
+			fix.title = "Insert missing comma"
+			fix.textEdit = lspTextEdit{Range: d.rangeOf(offset, offset), NewText: ","}
+			fix.diagnostic = lspDiagnostic{
+				Range:    d.rangeOf(offset, offset),
+				Severity: lspSeverityError,
+				Code:     "missing-comma",
+				Message:  "missing comma",
+			}
+		} else {
+			// it's a trailing comma if it's before the end of an object or
+			// an array (comments aside), otherwise a comma too many
+			message := "extra comma"
+			if next, ok := nextToken(tokens, offset+1); ok && (next.Kind == jsoncomma.TokenEndArray || next.Kind == jsoncomma.TokenEndObject) {
+				message = "trailing comma"
+			}
+			fix.title = "Remove " + message
+			fix.textEdit = lspTextEdit{Range: d.rangeOf(offset, offset+1), NewText: ""}
+			fix.diagnostic = lspDiagnostic{
+				Range:    d.rangeOf(offset, offset+1),
+				Severity: lspSeverityWarning,
+				Code:     strings.Replace(message, " ", "-", -1),
+				Message:  message,
+			}
+		}
+		fix.diagnostic.Source = "jsoncomma"
+		fixes[i] = fix
+	}
+	return fixes, nil
+}
+
+// nextToken returns the first token from offset which isn't a comment
+func nextToken(tokens []jsoncomma.Token, offset int) (jsoncomma.Token, bool) {
+	i := sort.Search(len(tokens), func(i int) bool {
+		return tokens[i].Offset >= offset
+	})
+	for ; i < len(tokens); i++ {
+		if tokens[i].Kind != jsoncomma.TokenComment {
+			return tokens[i], true
+		}
+	}
+	return jsoncomma.Token{}, false
+}
+
+// fixHunks groups the fixes which must be applied together (see
+// jsoncomma.Hunks), like the insertion and the removal of a moved comma
+func (d *lspDocument) fixHunks(fixes []commaFix) [][]commaFix {
+	edits := make([]jsoncomma.Edit, len(fixes))
+	for i, fix := range fixes {
+		edits[i] = fix.edit
+	}
+	var hunks [][]commaFix
+	for _, hunk := range jsoncomma.Hunks([]byte(d.text), edits) {
+		hunks = append(hunks, fixes[:len(hunk)])
+		fixes = fixes[len(hunk):]
+	}
+	return hunks
+}
+
+// lspServer is the state of the language server
+type lspServer struct {
+	rpc    *rpcServer
+	config *jsoncomma.Config
+
+	mu          sync.Mutex
+	initialized bool
+	shutdown    bool
+	documents   map[string]*lspDocument
+}
+
+// serveLSP runs the language server until the client sends exit, or stdin
+// is closed. Like the protocol says, it exits with the status 1 if the
+// client exits without a shutdown request first.
+func serveLSP(in io.Reader, out io.Writer, compat jsoncomma.Version) error {
+	s := &lspServer{
+		rpc:       newRPCServer(newRPCConn(in, out)),
+		config:    &jsoncomma.Config{Version: compat},
+		documents: map[string]*lspDocument{},
+	}
+
+	requests := map[string]rpcHandler{
+		"initialize":                   s.initialize,
+		"shutdown":                     s.shutdownRequest,
+		"textDocument/formatting":      s.formatting,
+		"textDocument/rangeFormatting": s.rangeFormatting,
+		"textDocument/codeAction":      s.codeAction,
+	}
+	for method, handler := range requests {
+		s.rpc.requests[method] = s.checkState(method, handler)
+		// the requests are handled in order with the notifications, so that
+		// they see the text the client had when it sent them (fixing is fast
+		// enough not to need concurrency)
+		s.rpc.inline[method] = true
+	}
+
+	s.rpc.notifications["textDocument/didOpen"] = s.didOpen
+	s.rpc.notifications["textDocument/didChange"] = s.didChange
+	s.rpc.notifications["textDocument/didClose"] = s.didClose
+	exited := false
+	s.rpc.notifications["exit"] = func(params json.RawMessage) {
+		exited = true
+		s.rpc.stop()
+	}
+
+	if err := s.rpc.serve(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !exited || !s.shutdown {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// checkState refuses the requests sent before initialize, or after shutdown
+func (s *lspServer) checkState(method string, handler rpcHandler) rpcHandler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		s.mu.Lock()
+		initialized, shutdown := s.initialized, s.shutdown
+		s.mu.Unlock()
+		if !initialized && method != "initialize" {
+			return nil, &rpcError{Code: lspServerNotStarted, Message: "the server isn't initialized"}
+		}
+		if shutdown {
+			return nil, &rpcError{Code: rpcInvalidRequest, Message: "the server is shutting down"}
+		}
+		return handler(ctx, params)
+	}
+}
+
+func (s *lspServer) initialize(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.initialized {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "the server is already initialized"}
+	}
+	s.initialized = true
+	return kv{
+		"capabilities": kv{
+			"positionEncoding": "utf-16",
+			"textDocumentSync": kv{
+				"openClose": true,
+				"change":    lspSyncIncremental,
+			},
+			"documentFormattingProvider":      true,
+			"documentRangeFormattingProvider": true,
+			"codeActionProvider": kv{
+				"codeActionKinds": []string{"quickfix", "source.fixAll"},
+			},
+		},
+		"serverInfo": kv{
+			"name":    "jsoncomma",
+			"version": version,
+		},
+	}, nil
+}
+
+func (s *lspServer) shutdownRequest(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown = true
+	return nil, nil
+}
+
+// document returns the open document with this uri
+func (s *lspServer) document(uri string) (*lspDocument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("%s isn't open", uri)}
+	}
+	return doc, nil
+}
+
+func (s *lspServer) didOpen(raw json.RawMessage) {
+	var params struct {
+		TextDocument lspTextDocument `json:"textDocument"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		log.Printf("didOpen: %s", err)
+		return
+	}
+	doc := newLSPDocument(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text)
+	s.mu.Lock()
+	s.documents[doc.uri] = doc
+	s.mu.Unlock()
+	s.publishDiagnostics(doc)
+}
+
+func (s *lspServer) didChange(raw json.RawMessage) {
+	var params struct {
+		TextDocument   lspTextDocument `json:"textDocument"`
+		ContentChanges []struct {
+			// Range is nil if the change is the whole text
+			Range *lspRange `json:"range"`
+			Text  string    `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		log.Printf("didChange: %s", err)
+		return
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		log.Printf("didChange: %s", err)
+		return
+	}
+	// the changes are applied one after the other, each one's range is in
+	// the text left by the previous one
+	for _, change := range params.ContentChanges {
+		text := change.Text
+		if change.Range != nil {
+			start, end := doc.offset(change.Range.Start), doc.offset(change.Range.End)
+			if end < start {
+				start, end = end, start
+			}
+			text = doc.text[:start] + change.Text + doc.text[end:]
+		}
+		doc = newLSPDocument(doc.uri, params.TextDocument.Version, text)
+	}
+	s.mu.Lock()
+	s.documents[doc.uri] = doc
+	s.mu.Unlock()
+	s.publishDiagnostics(doc)
+}
+
+func (s *lspServer) didClose(raw json.RawMessage) {
+	var params struct {
+		TextDocument lspTextDocument `json:"textDocument"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		log.Printf("didClose: %s", err)
+		return
+	}
+	s.mu.Lock()
+	delete(s.documents, params.TextDocument.URI)
+	s.mu.Unlock()
+	// clear the diagnostics, the document isn't ours anymore
+	s.notify("textDocument/publishDiagnostics", kv{
+		"uri":         params.TextDocument.URI,
+		"diagnostics": []lspDiagnostic{},
+	})
+}
+
+func (s *lspServer) publishDiagnostics(doc *lspDocument) {
+	fixes, err := doc.fixes(context.Background(), s.config)
+	if err != nil {
+		log.Printf("%s: %s", doc.uri, err)
+		return
+	}
+	diagnostics := make([]lspDiagnostic, len(fixes))
+	for i, fix := range fixes {
+		diagnostics[i] = fix.diagnostic
+	}
+	s.notify("textDocument/publishDiagnostics", kv{
+		"uri":         doc.uri,
+		"version":     doc.version,
+		"diagnostics": diagnostics,
+	})
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	if err := s.rpc.notify(method, params); err != nil {
+		log.Printf("%s: %s", method, err)
+	}
+}
+
+// documentParams are the params of the requests on a document. Range is
+// only set for rangeFormatting and codeAction.
+type documentParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Range        lspRange        `json:"range"`
+}
+
+// hunkInRange is whether one of the hunk's edits is between start and end
+// (offsets)
+func hunkInRange(hunk []commaFix, start, end int) bool {
+	for _, fix := range hunk {
+		if offset := int(fix.edit.Offset); start <= offset && offset <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// textEdits returns the edits of the hunks which are (even partly) between
+// start and end (offsets). A hunk is never split: half of a moved comma
+// leaves two commas, or none.
+func textEdits(hunks [][]commaFix, start, end int) []lspTextEdit {
+	edits := []lspTextEdit{}
+	for _, hunk := range hunks {
+		if !hunkInRange(hunk, start, end) {
+			continue
+		}
+		for _, fix := range hunk {
+			edits = append(edits, fix.textEdit)
+		}
+	}
+	return edits
+}
+
+func (s *lspServer) formatting(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params documentParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := doc.fixes(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+	return textEdits(doc.fixHunks(fixes), 0, len(doc.text)), nil
+}
+
+func (s *lspServer) rangeFormatting(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params documentParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := doc.fixes(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+	return textEdits(doc.fixHunks(fixes), doc.offset(params.Range.Start), doc.offset(params.Range.End)), nil
+}
+
+// codeAction offers a quick fix for each comma in the range (a moved comma
+// is a single fix), and a fix for every comma in the document if there are
+// several
+func (s *lspServer) codeAction(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params documentParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := doc.fixes(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []kv{}
+	start, end := doc.offset(params.Range.Start), doc.offset(params.Range.End)
+	for _, hunk := range doc.fixHunks(fixes) {
+		if !hunkInRange(hunk, start, end) {
+			continue
+		}
+		var edits []jsoncomma.Edit
+		var diagnostics []lspDiagnostic
+		var changes []lspTextEdit
+		for _, fix := range hunk {
+			edits = append(edits, fix.edit)
+			diagnostics = append(diagnostics, fix.diagnostic)
+			changes = append(changes, fix.textEdit)
+		}
+		title := hunk[0].title
+		if len(hunk) > 1 {
+			title = describeHunk(edits)
+			title = strings.ToUpper(title[:1]) + title[1:]
+		}
+		actions = append(actions, kv{
+			"title":       title,
+			"kind":        "quickfix",
+			"diagnostics": diagnostics,
+			"isPreferred": true,
+			"edit": kv{
+				"changes": map[string][]lspTextEdit{doc.uri: changes},
+			},
+		})
+	}
+	if len(fixes) > 1 {
+		diagnostics := make([]lspDiagnostic, len(fixes))
+		for i, fix := range fixes {
+			diagnostics[i] = fix.diagnostic
+		}
+		actions = append(actions, kv{
+			"title":       "Fix all the commas",
+			"kind":        "source.fixAll",
+			"diagnostics": diagnostics,
+			"edit": kv{
+				"changes": map[string][]lspTextEdit{doc.uri: textEdits(doc.fixHunks(fixes), 0, len(doc.text))},
+			},
+		})
+	}
+	return actions, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestLSPDocumentPositions(t *testing.T) {
+	// 😀 is 4 bytes in UTF-8, and 2 code units in UTF-16
+	doc := newLSPDocument("file:///a.json", 1, "[\"é😀\" 1\r\n2\r3\n]")
+	rows := []struct {
+		offset int
+		pos    lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{1, lspPosition{0, 1}},
+		{8, lspPosition{0, 5}},
+		{10, lspPosition{0, 7}},
+		{13, lspPosition{1, 0}},
+		{15, lspPosition{2, 0}},
+		{18, lspPosition{3, 1}},
+	}
+	for _, row := range rows {
+		if pos := doc.position(row.offset); pos != row.pos {
+			t.Errorf("position(%d): actual %v, expected %v", row.offset, pos, row.pos)
+		}
+		if offset := doc.offset(row.pos); offset != row.offset {
+			t.Errorf("offset(%v): actual %d, expected %d", row.pos, offset, row.offset)
+		}
+	}
+	// past the end of the line, before the \r\n
+	if offset := doc.offset(lspPosition{0, 100}); offset != 11 {
+		t.Errorf("expected the end of the line, got %d", offset)
+	}
+	if offset := doc.offset(lspPosition{100, 0}); offset != len(doc.text) {
+		t.Errorf("expected the end of the document, got %d", offset)
+	}
+}
+
+// readMessages reads every message written by the server, in order
+func readMessages(t *testing.T, out []byte) []kv {
+	conn := newRPCConn(bytes.NewReader(out), nil)
+	var messages []kv
+	for {
+		content, err := conn.read()
+		if err == io.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("reading the messages: %s", err)
+		}
+		var message kv
+		if err := json.Unmarshal(content, &message); err != nil {
+			t.Fatalf("invalid message %s: %s", content, err)
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestServeLSP(t *testing.T) {
+	uri := "file:///a.json"
+	in := strings.Join([]string{
+		frame(`{"jsonrpc": "2.0", "id": 0, "method": "textDocument/formatting", "params": {"textDocument": {"uri": "file:///a.json"}}}`),
+		frame(`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"capabilities": {}}}`),
+		frame(`{"jsonrpc": "2.0", "method": "initialized", "params": {}}`),
+		frame(`{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": {"textDocument": {"uri": "file:///a.json", "version": 1, "text": "{\"😀\": 1\n\"b\": [2,]}"}}}`),
+		// incremental: "1" becomes "10,"
+		frame(`{"jsonrpc": "2.0", "method": "textDocument/didChange", "params": {"textDocument": {"uri": "file:///a.json", "version": 2}, "contentChanges": [{"range": {"start": {"line": 0, "character": 7}, "end": {"line": 0, "character": 8}}, "text": "10,"}]}}`),
+		// full
+		frame(`{"jsonrpc": "2.0", "method": "textDocument/didChange", "params": {"textDocument": {"uri": "file:///a.json", "version": 3}, "contentChanges": [{"text": "[1 2,]"}]}}`),
+		frame(`{"jsonrpc": "2.0", "id": 2, "method": "textDocument/formatting", "params": {"textDocument": {"uri": "file:///a.json"}}}`),
+		frame(`{"jsonrpc": "2.0", "id": 3, "method": "textDocument/rangeFormatting", "params": {"textDocument": {"uri": "file:///a.json"}, "range": {"start": {"line": 0, "character": 3}, "end": {"line": 0, "character": 6}}}}`),
+		frame(`{"jsonrpc": "2.0", "id": 4, "method": "textDocument/codeAction", "params": {"textDocument": {"uri": "file:///a.json"}, "range": {"start": {"line": 0, "character": 2}, "end": {"line": 0, "character": 2}}, "context": {"diagnostics": []}}}`),
+		frame(`{"jsonrpc": "2.0", "method": "textDocument/didClose", "params": {"textDocument": {"uri": "file:///a.json"}}}`),
+		frame(`{"jsonrpc": "2.0", "id": 5, "method": "textDocument/formatting", "params": {"textDocument": {"uri": "file:///a.json"}}}`),
+		frame(`{"jsonrpc": "2.0", "id": 6, "method": "shutdown"}`),
+		frame(`{"jsonrpc": "2.0", "id": 7, "method": "textDocument/formatting", "params": {"textDocument": {"uri": "file:///a.json"}}}`),
+		frame(`{"jsonrpc": "2.0", "method": "exit"}`),
+	}, "")
+
+	var out bytes.Buffer
+	if err := serveLSP(strings.NewReader(in), &out, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := map[string]string{}
+	var diagnostics []string
+	for _, message := range readMessages(t, out.Bytes()) {
+		encoded, _ := json.Marshal(message)
+		if message["method"] == "textDocument/publishDiagnostics" {
+			params := message["params"].(map[string]interface{})
+			if params["uri"] != uri {
+				t.Errorf("unexpected uri: %s", encoded)
+			}
+			encoded, _ = json.Marshal(params["diagnostics"])
+			diagnostics = append(diagnostics, string(encoded))
+			continue
+		}
+		id := fmt.Sprint(message["id"])
+		if _, ok := message["result"]; ok {
+			encoded, _ = json.Marshal(message["result"])
+		} else {
+			encoded, _ = json.Marshal(message["error"].(map[string]interface{})["code"])
+		}
+		responses[id] = string(encoded)
+	}
+
+	expectedDiagnostics := []string{
+		// {"😀": 1 is 8 UTF-16 code units
+		`[{"code":"missing-comma","message":"missing comma","range":{"end":{"character":8,"line":0},"start":{"character":8,"line":0}},"severity":1,"source":"jsoncomma"},` +
+			`{"code":"trailing-comma","message":"trailing comma","range":{"end":{"character":8,"line":1},"start":{"character":7,"line":1}},"severity":2,"source":"jsoncomma"}]`,
+		`[{"code":"trailing-comma","message":"trailing comma","range":{"end":{"character":8,"line":1},"start":{"character":7,"line":1}},"severity":2,"source":"jsoncomma"}]`,
+		`[{"code":"missing-comma","message":"missing comma","range":{"end":{"character":2,"line":0},"start":{"character":2,"line":0}},"severity":1,"source":"jsoncomma"},` +
+			`{"code":"trailing-comma","message":"trailing comma","range":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}},"severity":2,"source":"jsoncomma"}]`,
+		`[]`,
+	}
+	if strings.Join(diagnostics, "\n") != strings.Join(expectedDiagnostics, "\n") {
+		t.Errorf("diagnostics:\nactual:\n%s\nexpected:\n%s", strings.Join(diagnostics, "\n"), strings.Join(expectedDiagnostics, "\n"))
+	}
+
+	if !strings.Contains(responses["1"], `"positionEncoding":"utf-16"`) {
+		t.Errorf("initialize: %s", responses["1"])
+	}
+	expected := map[string]string{
+		"0": "-32002",
+		"2": `[{"newText":",","range":{"end":{"character":2,"line":0},"start":{"character":2,"line":0}}},{"newText":"","range":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}}]`,
+		"3": `[{"newText":"","range":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}}]`,
+		"5": "-32602",
+		"6": "null",
+		"7": "-32600",
+	}
+	for id, response := range expected {
+		if responses[id] != response {
+			t.Errorf("id %s:\nactual:   %s\nexpected: %s", id, responses[id], response)
+		}
+	}
+
+	var actions []struct {
+		Title string
+		Kind  string
+	}
+	json.Unmarshal([]byte(responses["4"]), &actions)
+	if len(actions) != 2 || actions[0].Title != "Insert missing comma" || actions[1].Kind != "source.fixAll" {
+		t.Errorf("code actions: %s", responses["4"])
+	}
+}
+
+func TestServeLSPExitWithoutShutdown(t *testing.T) {
+	in := frame(`{"jsonrpc": "2.0", "method": "exit"}`)
+	if err := serveLSP(strings.NewReader(in), ioutil.Discard, 0); err != exitStatus(1) {
+		t.Errorf("expected the exit status 1, got %v", err)
+	}
+}
+
+func TestLSPDocumentFixes(t *testing.T) {
+	rows := []struct {
+		text  string
+		codes []string
+	}{
+		{text: "[1 2]", codes: []string{"missing-comma"}},
+		{text: "[1,, 2]", codes: []string{"extra-comma"}},
+		{text: "[1, ]", codes: []string{"trailing-comma"}},
+		// the comments don't make it an extra comma
+		{text: "[1, // note\n]", codes: []string{"trailing-comma"}},
+		{text: "{\"a\": 1, /* note */ }", codes: []string{"trailing-comma"}},
+		{text: "[1 // note\n, 2]", codes: []string{"missing-comma", "extra-comma"}},
+	}
+	for _, row := range rows {
+		doc := newLSPDocument("file:///a.json", 1, row.text)
+		fixes, err := doc.fixes(context.Background(), &jsoncomma.Config{})
+		if err != nil {
+			t.Errorf("%q: %s", row.text, err)
+			continue
+		}
+		var codes []string
+		for _, fix := range fixes {
+			codes = append(codes, fix.diagnostic.Code)
+		}
+		if !reflect.DeepEqual(codes, row.codes) {
+			t.Errorf("%q: actual %q, expected %q", row.text, codes, row.codes)
+		}
+	}
+}
+
+func TestLSPCodeActionMovedComma(t *testing.T) {
+	uri := "file:///a.json"
+	s := &lspServer{
+		config:    &jsoncomma.Config{},
+		documents: map[string]*lspDocument{uri: newLSPDocument(uri, 1, "[1 // note\n, 2]")},
+	}
+	params := `{"textDocument": {"uri": "file:///a.json"}, "range": {"start": {"line": 0, "character": 0}, "end": {"line": 1, "character": 3}}, "context": {"diagnostics": []}}`
+	result, err := s.codeAction(context.Background(), json.RawMessage(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(result)
+	var actions []struct {
+		Title       string
+		Kind        string
+		Diagnostics []lspDiagnostic
+		Edit        struct {
+			Changes map[string][]lspTextEdit
+		}
+	}
+	if err := json.Unmarshal(encoded, &actions); err != nil {
+		t.Fatal(err)
+	}
+
+	// one action, with both edits, and no fix all (it would be the same)
+	if len(actions) != 2 {
+		t.Fatalf("expected the quick fix and the fix all, got %s", encoded)
+	}
+	action := actions[0]
+	if action.Title != "Move comma" || action.Kind != "quickfix" || len(action.Diagnostics) != 2 {
+		t.Errorf("unexpected action: %s", encoded)
+	}
+	expected := []lspTextEdit{
+		{Range: lspRange{lspPosition{0, 2}, lspPosition{0, 2}}, NewText: ","},
+		{Range: lspRange{lspPosition{1, 0}, lspPosition{1, 1}}, NewText: ""},
+	}
+	if !reflect.DeepEqual(action.Edit.Changes[uri], expected) {
+		t.Errorf("actual edits %v, expected %v", action.Edit.Changes[uri], expected)
+	}
+}
+
+func TestLSPRangeFormattingMovedComma(t *testing.T) {
+	uri := "file:///a.json"
+	s := &lspServer{
+		config:    &jsoncomma.Config{},
+		documents: map[string]*lspDocument{uri: newLSPDocument(uri, 1, "[1\n ,2]")},
+	}
+	// only the first line: the removal on the second line comes with the
+	// insertion, or the document ends up with two commas
+	params := `{"textDocument": {"uri": "file:///a.json"}, "range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 2}}}`
+	result, err := s.rangeFormatting(context.Background(), json.RawMessage(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []lspTextEdit{
+		{Range: lspRange{lspPosition{0, 2}, lspPosition{0, 2}}, NewText: ","},
+		{Range: lspRange{lspPosition{1, 1}, lspPosition{1, 2}}, NewText: ""},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("actual %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	rows := []struct {
+		args []string
+		cmd  *command
+		rest []string
+	}{
+		{args: nil, cmd: fixCmd, rest: nil},
+		{args: []string{"a.json"}, cmd: fixCmd, rest: []string{"a.json"}},
+		{args: []string{"-stdout", "a.json"}, cmd: fixCmd, rest: []string{"-stdout", "a.json"}},
+		{args: []string{"fix", "a.json"}, cmd: fixCmd, rest: []string{"a.json"}},
+		{args: []string{"check", "a.json", "b.json"}, cmd: checkCmd, rest: []string{"a.json", "b.json"}},
+		{args: []string{"server", "-port", "8000"}, cmd: serverCmd, rest: []string{"-port", "8000"}},
+		// a file named like a command can still be fixed
+		{args: []string{"./server"}, cmd: fixCmd, rest: []string{"./server"}},
+		{args: []string{"fix", "server"}, cmd: fixCmd, rest: []string{"server"}},
+	}
+	for _, row := range rows {
+		cmd, rest := dispatch(row.args)
+		if cmd != row.cmd || !reflect.DeepEqual(rest, row.rest) {
+			t.Errorf("%q: actual %s %q, expected %s %q", row.args, cmd.name, rest, row.cmd.name, row.rest)
+		}
+	}
+}
+
+func TestCommandNames(t *testing.T) {
+	seen := map[string]bool{}
+	for _, cmd := range commands {
+		if seen[cmd.name] {
+			t.Errorf("the command %q is registered twice", cmd.name)
+		}
+		seen[cmd.name] = true
+		if cmd.run == nil {
+			t.Errorf("the command %q has no run function", cmd.name)
+		}
+		// the help lists the short descriptions, one per line
+		if cmd.short == "" || strings.Contains(cmd.short, "\n") {
+			t.Errorf("the command %q should have a single line short description, got %q", cmd.name, cmd.short)
+		}
+		if lookupCommand(cmd.name) != cmd {
+			t.Errorf("lookupCommand(%q) doesn't find the command", cmd.name)
+		}
+	}
+	if lookupCommand("nope") != nil {
+		t.Errorf("expected no command named nope")
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	rows := []struct {
+		args       []string
+		positional []string
+		output     string
+		verbose    bool
+	}{
+		{args: []string{"a", "b"}, positional: []string{"a", "b"}},
+		{args: []string{"-o", "out", "a"}, positional: []string{"a"}, output: "out"},
+		{args: []string{"a", "-o", "out", "b"}, positional: []string{"a", "b"}, output: "out"},
+		{args: []string{"a", "-v"}, positional: []string{"a"}, verbose: true},
+		{args: []string{"-v", "--", "-o", "a"}, positional: []string{"-o", "a"}, verbose: true},
+		{args: []string{"a", "--", "-v"}, positional: []string{"a", "-v"}},
+		{args: []string{"-", "-o", "out"}, positional: []string{"-"}, output: "out"},
+	}
+	for _, row := range rows {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		output := flags.String("o", "", "")
+		verbose := flags.Bool("v", false, "")
+		positional, err := parseInterspersed(flags, row.args)
+		if err != nil {
+			t.Errorf("%q: %s", row.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(positional, row.positional) || *output != row.output || *verbose != row.verbose {
+			t.Errorf("%q: actual %q -o %q -v %t, expected %q -o %q -v %t", row.args, positional, *output, *verbose, row.positional, row.output, row.verbose)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var mergeCmd = &command{
+	name:  "merge",
+	args:  "base patch...",
+	short: "Applies JSON Merge Patches (RFC 7386) to base, keeping its comments",
+	long: "The patches are applied in order, like layered configs (base, environment,\n" +
+		"local). The result keeps the formatting and the comments of base, and gets\n" +
+		"the comments of the patches' members.",
+	flags: flag.NewFlagSet("merge", flag.ExitOnError),
+	files: true,
+}
+
+var mergeOutput = mergeCmd.flags.String("o", "", "write to this `file` instead of stdout")
+var mergeInPlace = mergeCmd.flags.Bool("w", false, "write the result to base")
+var mergeInput = addInputFlags(mergeCmd.flags)
+var mergeCompat = addCompatFlag(mergeCmd.flags)
+
+func init() {
+	mergeCmd.run = runMerge
+}
+
+func runMerge(args []string) error {
+	if len(args) < 2 {
+		mergeCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	if *mergeInPlace && *mergeOutput != "" {
+		return fmt.Errorf("-w and -o are incompatible")
+	}
+	if _, err := mergeInput.resolve(args); err != nil {
+		return err
+	}
+	if *mergeInPlace && args[0] == stdinArg {
+		return fmt.Errorf("-w can't write to stdin")
+	}
+
+	config := &jsoncomma.Config{Version: *mergeCompat}
+
+	baseName := mergeInput.displayName(args[0])
+	base, err := readInput(args[0], baseName)
+	if err != nil {
+		return fmt.Errorf("reading %q: %s", baseName, err)
+	}
+	for _, filename := range args[1:] {
+		name := mergeInput.displayName(filename)
+		patch, err := readInput(filename, name)
+		if err != nil {
+			return fmt.Errorf("reading %q: %s", name, err)
+		}
+		if base, err = jsoncomma.MergePatch(config, base, patch); err != nil {
+			return fmt.Errorf("merging %q: %s", name, err)
+		}
+	}
+
+	// the merge only fixes the commas around what it changes, the rest of
+	// base is kept as is
+	switch {
+	case *mergeInPlace:
+		return writeInPlace(args[0], base)
+	case *mergeOutput != "":
+		return ioutil.WriteFile(*mergeOutput, base, 0644)
+	}
+	_, err = os.Stdout.Write(base)
+	return err
+}
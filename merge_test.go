@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestMergeKeepsBase(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"base.json":  "{\n\t\"a\": 1 // no comma\n\t\"b\": [1 2]\n\t\"c\": 3,\n}",
+			"patch.json": "{\"c\": 4, \"d\": 5}",
+		})
+		var err error
+		out := withStdio(t, nil, func() {
+			err = runMerge([]string{"base.json", "patch.json"})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the commas around c and d are fixed
+		expected := "{\n\t\"a\": 1 // no comma\n\t\"b\": [1 2]\n\t\"c\": 4,\n\t\"d\": 5\n}"
+		if string(out) != expected {
+			t.Errorf("actual:\n%s\nexpected:\n%s", out, expected)
+		}
+	})
+}
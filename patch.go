@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// patchContext is the number of lines shown before and after each change
+const patchContext = 2
+
+const patchHelp = `y - apply this change
+n - do not apply this change
+a - apply this change and all the remaining ones in the file
+d - do not apply this change nor any of the remaining ones in the file
+q - quit, do not apply this change nor any of the remaining ones
+? - print help
+`
+
+// patcher asks whether each edit should be applied, like git add -p
+type patcher struct {
+	answers *bufio.Reader
+	out     io.Writer
+	// quit is true once the user doesn't want to review anything else
+	quit bool
+}
+
+// runPatch reviews the edits of every file, and writes the accepted ones
+// in place
+func runPatch(config *jsoncomma.Config, filenames []string, answers io.Reader, out io.Writer) error {
+	p := &patcher{
+		answers: bufio.NewReader(answers),
+		out:     out,
+	}
+
+	for _, filename := range filenames {
+		if p.quit {
+			break
+		}
+		if err := p.patchFile(config, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *patcher) patchFile(config *jsoncomma.Config, filename string) error {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if detectCompression(bufio.NewReader(bytes.NewReader(content)), filename) != uncompressed {
+		return fmt.Errorf("patching %q: compressed files aren't supported in patch mode", filename)
+	}
+
+	edits, err := jsoncomma.Edits(config, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("patching %q: %s", filename, err)
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+
+	accepted, err := p.review(filename, content, edits)
+	if err != nil {
+		return err
+	}
+	if len(accepted) == 0 {
+		return nil
+	}
+
+	patched := jsoncomma.Apply(content, accepted)
+	if err := ioutil.WriteFile(filename, patched, stat.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing %q: %s", filename, err)
+	}
+	fmt.Fprintf(p.out, "%s: applied %d of %d changes\n", filename, len(accepted), len(edits))
+	return nil
+}
+
+// review asks about each hunk (the edits in the same gap, see
+// jsoncomma.Hunks), and returns the edits of the accepted ones
+func (p *patcher) review(name string, content []byte, edits []jsoncomma.Edit) ([]jsoncomma.Edit, error) {
+	var accepted []jsoncomma.Edit
+	hunks := jsoncomma.Hunks(content, edits)
+
+	for i, hunk := range hunks {
+		p.show(name, content, hunk)
+
+		for {
+			fmt.Fprintf(p.out, "(%d/%d) %s [y,n,a,d,q,?]? ", i+1, len(hunks), describeHunk(hunk))
+
+			line, err := p.answers.ReadString('\n')
+			if err == io.EOF && line == "" {
+				// no more answers, nothing else is applied
+				fmt.Fprintln(p.out)
+				p.quit = true
+				return accepted, nil
+			} else if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("reading answer: %s", err)
+			}
+
+			switch strings.TrimSpace(line) {
+			case "y":
+				accepted = append(accepted, hunk...)
+			case "n":
+			case "a":
+				for _, hunk := range hunks[i:] {
+					accepted = append(accepted, hunk...)
+				}
+				return accepted, nil
+			case "d":
+				return accepted, nil
+			case "q":
+				p.quit = true
+				return accepted, nil
+			default:
+				fmt.Fprint(p.out, patchHelp)
+				continue
+			}
+			break
+		}
+	}
+	return accepted, nil
+}
+
+// describeHunk says what the hunk does, like "move comma"
+func describeHunk(hunk []jsoncomma.Edit) string {
+	inserted, removed := 0, 0
+	for _, edit := range hunk {
+		if edit.Kind == jsoncomma.Insert {
+			inserted++
+		} else {
+			removed++
+		}
+	}
+	switch {
+	case inserted == 1 && removed == 0:
+		return "insert comma"
+	case inserted == 0 && removed == 1:
+		return "remove comma"
+	case inserted == 1 && removed == 1:
+		return "move comma"
+	case inserted == 0:
+		return fmt.Sprintf("remove %d commas", removed)
+	}
+	return fmt.Sprintf("move comma, remove %d commas", removed-1)
+}
+
+// lineOf returns the index of the line containing offset, given the lines
+// of the content
+func lineOf(lines [][]byte, offset int64) (int, int64) {
+	line := 0
+	var lineStart int64
+	for line < len(lines)-1 && lineStart+int64(len(lines[line])) <= offset {
+		lineStart += int64(len(lines[line]))
+		line++
+	}
+	return line, lineStart
+}
+
+// show prints the lines changed by the hunk with the lines around them,
+// before and after the hunk
+func (p *patcher) show(name string, content []byte, hunk []jsoncomma.Edit) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		// content ends with a new line
+		lines = lines[:len(lines)-1]
+	}
+
+	// the lines the hunk changes
+	firstChanged, changedStart := lineOf(lines, hunk[0].Offset)
+	lastChanged, _ := lineOf(lines, hunk[len(hunk)-1].Offset)
+
+	first := firstChanged - patchContext
+	if first < 0 {
+		first = 0
+	}
+	last := lastChanged + patchContext
+	if last > len(lines)-1 {
+		last = len(lines) - 1
+	}
+
+	// the hunk, relative to the changed lines
+	changed := bytes.Join(lines[firstChanged:lastChanged+1], nil)
+	relative := make([]jsoncomma.Edit, len(hunk))
+	for i, edit := range hunk {
+		relative[i] = jsoncomma.Edit{Kind: edit.Kind, Offset: edit.Offset - changedStart}
+	}
+	after := bytes.SplitAfter(jsoncomma.Apply(changed, relative), []byte("\n"))
+
+	fmt.Fprintf(p.out, "@@ %s:%d:%d @@\n", name, firstChanged+1, hunk[0].Offset-changedStart+1)
+	for i := first; i <= last; i++ {
+		text := strings.TrimRight(string(lines[i]), "\r\n")
+		if i < firstChanged || i > lastChanged {
+			fmt.Fprintf(p.out, "  %5d | %s\n", i+1, text)
+			continue
+		}
+		fmt.Fprintf(p.out, "- %5d | %s\n", i+1, text)
+		if i == lastChanged {
+			// the lines after the hunk, all at once since the number of
+			// lines doesn't change
+			for j, line := range after {
+				if len(line) == 0 {
+					continue
+				}
+				fmt.Fprintf(p.out, "+ %5d | %s\n", firstChanged+j+1, strings.TrimRight(string(line), "\r\n"))
+			}
+		}
+	}
+}
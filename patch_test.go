@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestPatch(t *testing.T) {
+	table := []struct {
+		in      string
+		answers string
+		out     string
+	}{
+		{
+			in:      "[1 2 3,]",
+			answers: "y\nn\ny\n",
+			out:     "[1, 2 3]",
+		},
+		{
+			in:      "[1 2 3,]",
+			answers: "n\na\n",
+			out:     "[1 2, 3]",
+		},
+		{
+			in:      "[1 2 3,]",
+			answers: "y\nd\n",
+			out:     "[1, 2 3,]",
+		},
+		{
+			// invalid answers print the help, and ask again
+			in:      "[1 2 3,]",
+			answers: "what\ny\nq\n",
+			out:     "[1, 2 3,]",
+		},
+		{
+			// no more answers
+			in:      "[1 2 3,]",
+			answers: "y\n",
+			out:     "[1, 2 3,]",
+		},
+		{
+			in:      "[1, 2]",
+			answers: "",
+			out:     "[1, 2]",
+		},
+		{
+			// moved commas are a single change each: accepting one never
+			// leaves two commas, or none
+			in:      "[1\n, 2\n, 3]",
+			answers: "y\nn\n",
+			out:     "[1,\n 2\n, 3]",
+		},
+		{
+			in:      "[1 ,2 ,3]",
+			answers: "n\ny\n",
+			out:     "[1 ,2, 3]",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "jsoncomma-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, row := range table {
+		filename := filepath.Join(dir, "file.json")
+		if err := ioutil.WriteFile(filename, []byte(row.in), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := runPatch(&jsoncomma.Config{}, []string{filename}, strings.NewReader(row.answers), &out); err != nil {
+			t.Fatalf("row %d: %s", i, err)
+		}
+
+		actual, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != row.out {
+			t.Errorf("row %d: in: %#q, answers: %#q\nactual:   %#q\nexpected: %#q\noutput:\n%s", i, row.in, row.answers, actual, row.out, out.String())
+		}
+	}
+}
+
+func TestPatchQuitSkipsRemainingFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsoncomma-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	for _, filename := range []string{first, second} {
+		if err := ioutil.WriteFile(filename, []byte("[1 2]"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runPatch(&jsoncomma.Config{}, []string{first, second}, strings.NewReader("q\ny\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range []string{first, second} {
+		actual, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != "[1 2]" {
+			t.Errorf("%s: expected to be untouched, got %#q", filename, actual)
+		}
+	}
+	if !strings.Contains(out.String(), "+     1 | [1, 2]") {
+		t.Errorf("expected the change to be shown, got:\n%s", out.String())
+	}
+}
+
+func TestPatchShowsMovedComma(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsoncomma-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "file.json")
+	if err := ioutil.WriteFile(filename, []byte("[\n\t1\n\t, 2\n]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runPatch(&jsoncomma.Config{}, []string{filename}, strings.NewReader("n\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "@@ " + filename + ":2:3 @@\n" +
+		"      1 | [\n" +
+		"-     2 | \t1\n" +
+		"-     3 | \t, 2\n" +
+		"+     2 | \t1,\n" +
+		"+     3 | \t 2\n" +
+		"      4 | ]\n" +
+		"(1/1) move comma [y,n,a,d,q,?]? "
+	if out.String() != expected {
+		t.Errorf("actual:\n%q\nexpected:\n%q", out.String(), expected)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var getCmd = &command{
+	name:  "get",
+	args:  "file pointer",
+	short: "Prints the value at a JSON Pointer, as written in the file",
+	long:  "Pointers look like /servers/0/host.",
+	flags: flag.NewFlagSet("get", flag.ExitOnError),
+	files: true,
+}
+
+var getRaw = getCmd.flags.Bool("r", false, "print strings without the quotes, and without escape sequences")
+var getInput = addInputFlags(getCmd.flags)
+
+// getCompat is accepted so that scripts can pass the same -compat to every
+// command, but get doesn't change any comma
+var getCompat = addCompatFlag(getCmd.flags)
+
+var setCmd = &command{
+	name:  "set",
+	args:  "file pointer value",
+	short: "Sets the value at a JSON Pointer, keeping the comments and the formatting",
+	long:  "Only the value changes. /list/- appends to the list.",
+	flags: flag.NewFlagSet("set", flag.ExitOnError),
+	files: true,
+}
+
+var setToStdout = setCmd.flags.Bool("stdout", false, "write to stdout instead of in place")
+var setInput = addInputFlags(setCmd.flags)
+var setCompat = addCompatFlag(setCmd.flags)
+
+var deleteCmd = &command{
+	name:  "delete",
+	args:  "file pointer",
+	short: "Deletes the value at a JSON Pointer, and its comments",
+	long:  "The rest of the file is kept as is.",
+	flags: flag.NewFlagSet("delete", flag.ExitOnError),
+	files: true,
+}
+
+var deleteToStdout = deleteCmd.flags.Bool("stdout", false, "write to stdout instead of in place")
+var deleteInput = addInputFlags(deleteCmd.flags)
+var deleteCompat = addCompatFlag(deleteCmd.flags)
+
+func init() {
+	getCmd.run = runGet
+	setCmd.run = runSet
+	deleteCmd.run = runDelete
+}
+
+func runGet(args []string) error {
+	if len(args) != 2 {
+		getCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	filename, pointer := args[0], args[1]
+	name := getInput.displayName(filename)
+
+	content, err := readInput(filename, name)
+	if err != nil {
+		return fmt.Errorf("reading %q: %s", name, err)
+	}
+	doc, err := jsoncomma.Parse(content)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %s", name, err)
+	}
+	value, err := doc.Get(pointer)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	if *getRaw && value.Kind == jsoncomma.String {
+		s, err := value.Str()
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+		return nil
+	}
+	fmt.Printf("%s\n", content[value.Start:value.End])
+	return nil
+}
+
+func runSet(args []string) error {
+	if len(args) != 3 {
+		setCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	return edit(setInput, args[0], *setToStdout, func(content []byte) ([]byte, error) {
+		return jsoncomma.Set(&jsoncomma.Config{Version: *setCompat}, content, args[1], []byte(args[2]))
+	})
+}
+
+func runDelete(args []string) error {
+	if len(args) != 2 {
+		deleteCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	return edit(deleteInput, args[0], *deleteToStdout, func(content []byte) ([]byte, error) {
+		return jsoncomma.Delete(&jsoncomma.Config{Version: *deleteCompat}, content, args[1])
+	})
+}
+
+// edit applies the change to the file, in place, or to stdout if tostdout
+// is true or if the file is stdin
+func edit(in *inputFlags, filename string, tostdout bool, change func(content []byte) ([]byte, error)) error {
+	name := in.displayName(filename)
+	content, err := readInput(filename, name)
+	if err != nil {
+		return fmt.Errorf("reading %q: %s", name, err)
+	}
+	edited, err := change(content)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	if tostdout || filename == stdinArg {
+		_, err := os.Stdout.Write(edited)
+		return err
+	}
+	return writeInPlace(filename, edited)
+}
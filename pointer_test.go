@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPointerCommandsStdin(t *testing.T) {
+	rows := []struct {
+		in      *inputFlags
+		run     func() error
+		stdin   string
+		out     string
+		errName string
+	}{
+		{
+			in:    getInput,
+			run:   func() error { return runGet([]string{stdinArg, "/a/1"}) },
+			stdin: `{"a": [1 2]}`,
+			out:   "2\n",
+		},
+		{
+			in:      getInput,
+			run:     func() error { return runGet([]string{stdinArg, "/b"}) },
+			stdin:   `{"a": 1}`,
+			errName: "conf/a.json",
+		},
+		{
+			in:    setInput,
+			run:   func() error { return runSet([]string{stdinArg, "/a", "2"}) },
+			stdin: `{"a": 1}`,
+			out:   `{"a": 2}`,
+		},
+		{
+			in:      setInput,
+			run:     func() error { return runSet([]string{stdinArg, "/a/b", "2"}) },
+			stdin:   `{"a": 1}`,
+			errName: "conf/a.json",
+		},
+		{
+			in:    deleteInput,
+			run:   func() error { return runDelete([]string{stdinArg, "/b"}) },
+			stdin: `{"a": 1, "b": 2}`,
+			out:   `{"a": 1}`,
+		},
+		{
+			in:      deleteInput,
+			run:     func() error { return runDelete([]string{stdinArg, "/c"}) },
+			stdin:   `{"a": 1}`,
+			errName: "conf/a.json",
+		},
+	}
+	for i, row := range rows {
+		old := *row.in.stdinFilename
+		*row.in.stdinFilename = "conf/a.json"
+		var err error
+		out := withStdio(t, []byte(row.stdin), func() {
+			err = row.run()
+		})
+		*row.in.stdinFilename = old
+
+		if row.errName != "" {
+			if err == nil || !strings.Contains(err.Error(), row.errName) {
+				t.Errorf("%d: expected an error about %s, got %v", i, row.errName, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %s", i, err)
+		} else if string(out) != row.out {
+			t.Errorf("%d: actual %q, expected %q", i, out, row.out)
+		}
+	}
+}
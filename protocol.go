@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// protocolVersions are the versions of the HTTP protocol the server speaks.
+// Clients pick one with the X-Protocol-Version header (1 if they don't),
+// and the server behaves the same for a given version forever: a change
+// which could break a client needs a new version.
+var protocolVersions = []int{1, 2}
+
+// latestProtocol is the highest of protocolVersions
+const latestProtocol = 2
+
+// protocolCapabilities are what each protocol version offers, so that
+// plugins don't have to know which version brought what
+var protocolCapabilities = map[int][]string{
+	// POST / fixes the body (?compat=v1 picks the heuristics), POST /detect
+	// tells if it's JSON-like, /shutdown stops the server
+	1: {"fix", "compat", "detect", "shutdown"},
+	// / only responds once the output is complete (an error is a 500 JSON
+	// error, instead of a truncated output), with the number of commas in
+	// the X-Inserted and X-Removed headers
+	2: {"fix", "compat", "detect", "shutdown", "fix-errors", "fix-counts"},
+}
+
+// capabilitiesByVersion returns protocolCapabilities with string keys, for
+// JSON
+func capabilitiesByVersion() map[string][]string {
+	capabilities := map[string][]string{}
+	for version, list := range protocolCapabilities {
+		capabilities[strconv.Itoa(version)] = list
+	}
+	return capabilities
+}
+
+// protocolVersion returns the version the request asks for
+func protocolVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("X-Protocol-Version"))
+	if header == "" {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Protocol-Version %q, expected an integer", header)
+	}
+	if _, ok := protocolCapabilities[version]; !ok {
+		return 0, fmt.Errorf("unsupported X-Protocol-Version %d, this server supports 1 to %d", version, latestProtocol)
+	}
+	return version, nil
+}
+
+// withProtocol gives the handler the protocol version of the request, which
+// is also set in the response. The requests for an unsupported version are
+// refused.
+func withProtocol(handler func(w http.ResponseWriter, r *http.Request, protocol int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		protocol, err := protocolVersion(r)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, kv{
+				"kind":      "unsupported protocol version",
+				"msg":       err.Error(),
+				"requested": r.Header.Get("X-Protocol-Version"),
+				"supported": protocolVersions,
+			})
+			return
+		}
+		w.Header().Set("X-Protocol-Version", strconv.Itoa(protocol))
+		handler(w, r, protocol)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var reduceCmd = &command{
+	name:  "reduce",
+	args:  "file",
+	short: "Shrinks an input on which jsoncomma fails to a minimal one that still fails",
+	long: "The result is printed with what jsoncomma outputs for it, ready to paste in\n" +
+		"an issue.",
+	flags: flag.NewFlagSet("reduce", flag.ExitOnError),
+	files: true,
+}
+
+var reducePredicate = reduceCmd.flags.String("predicate", "invalid-output",
+	"the failure to keep: "+strings.Join(predicateNames(), ", "))
+var reduceCompat = addCompatFlag(reduceCmd.flags)
+var reduceInput = addInputFlags(reduceCmd.flags)
+
+func init() {
+	reduceCmd.run = runReduce
+}
+
+func predicateNames() []string {
+	var names []string
+	for name := range jsoncomma.Predicates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runReduce(args []string) error {
+	if len(args) != 1 {
+		reduceCmd.flags.Usage()
+		return exitStatus(2)
+	}
+	newPredicate, ok := jsoncomma.Predicates[*reducePredicate]
+	if !ok {
+		return fmt.Errorf("unknown predicate %q, expected one of %s", *reducePredicate, strings.Join(predicateNames(), ", "))
+	}
+	config := &jsoncomma.Config{Version: *reduceCompat}
+	predicate := newPredicate(config)
+
+	name := reduceInput.displayName(args[0])
+	input, err := readInput(args[0], name)
+	if err != nil {
+		return fmt.Errorf("reading %q: %s", name, err)
+	}
+	if !predicate(input) {
+		return fmt.Errorf("%s doesn't reproduce %s, nothing to reduce", name, *reducePredicate)
+	}
+
+	reduced := jsoncomma.Reduce(input, predicate)
+	writeReport(os.Stdout, config, *reducePredicate, len(input), reduced)
+	return nil
+}
+
+// writeReport writes the reduced input as a markdown issue
+func writeReport(w io.Writer, config *jsoncomma.Config, predicate string, originalSize int, input []byte) {
+	fmt.Fprintf(w, "Found with `jsoncomma reduce -predicate %s -compat %s` (reduced from %d to %d bytes).\n\n", predicate, config.Version.Resolve(), originalSize, len(input))
+	fmt.Fprintf(w, "Input:\n\n%s\n", codeBlock(input))
+
+	expected, hasExpected := jsoncomma.Reference(input)
+	if hasExpected {
+		fmt.Fprintf(w, "Expected output:\n\n%s\n", codeBlock(expected))
+	}
+
+	actual, err := jsoncomma.FixBytes(config, input)
+	if err != nil {
+		fmt.Fprintf(w, "Actual: `Fix` fails with\n\n%s\n", codeBlock([]byte(err.Error())))
+	} else {
+		fmt.Fprintf(w, "Actual output:\n\n%s\n", codeBlock(actual))
+		if predicate == "not-idempotent" {
+			twice, _ := jsoncomma.FixBytes(config, actual)
+			fmt.Fprintf(w, "Fixing the output again gives:\n\n%s\n", codeBlock(twice))
+		}
+	}
+
+	if hasExpected {
+		fmt.Fprintf(w, "Test case (internals/jsoncommas_test.go):\n\n```go\n{\n\tin:  %#q,\n\tout: %#q,\n},\n```\n\n", input, expected)
+	}
+
+	fmt.Fprintf(w, "Build:\n\n```\n%s\n%s %s/%s\n```\n", versionLine(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// codeBlock quotes the content in a markdown code block, with a fence longer
+// than any backtick run in the content
+func codeBlock(content []byte) string {
+	fence := "```"
+	for bytes.Contains(content, []byte(fence)) {
+		fence += "`"
+	}
+	text := string(content)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return fence + "\n" + text + fence + "\n"
+}
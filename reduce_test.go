@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestCodeBlock(t *testing.T) {
+	table := []struct {
+		in, out string
+	}{
+		{in: "[1 2]", out: "```\n[1 2]\n```\n"},
+		{in: "[1 2]\n", out: "```\n[1 2]\n```\n"},
+		// the fence is longer than any backtick run of the content
+		{in: "[\"```\"]", out: "````\n[\"```\"]\n````\n"},
+		{in: "// ```` and ```\n[]", out: "`````\n// ```` and ```\n[]\n`````\n"},
+		{in: "[\"`\"]", out: "```\n[\"`\"]\n```\n"},
+	}
+	for _, row := range table {
+		if actual := codeBlock([]byte(row.in)); actual != row.out {
+			t.Errorf("%q: actual %q, expected %q", row.in, actual, row.out)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	config := &jsoncomma.Config{Version: jsoncomma.V1}
+
+	var report bytes.Buffer
+	writeReport(&report, config, "invalid-output", 42, []byte("[1 -2]"))
+	for _, expected := range []string{
+		"Found with `jsoncomma reduce -predicate invalid-output -compat v1` (reduced from 42 to 6 bytes).\n",
+		"Input:\n\n```\n[1 -2]\n```\n",
+		"Expected output:\n\n```\n[1, -2]\n```\n",
+		"Actual output:\n\n```\n[1 -2]\n```\n",
+		"{\n\tin:  `[1 -2]`,\n\tout: `[1, -2]`,\n},",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("expected the report to contain %q, got:\n%s", expected, report.String())
+		}
+	}
+	if strings.Contains(report.String(), "Fixing the output again") {
+		t.Errorf("only not-idempotent reports fix the output again, got:\n%s", report.String())
+	}
+
+	// the output is fixed again, and the fences are escaped; there is no
+	// expected output for input which isn't strict JSON
+	report.Reset()
+	writeReport(&report, config, "not-idempotent", 20, []byte("['```' 1 2]"))
+	for _, expected := range []string{
+		"Input:\n\n````\n['```' 1 2]\n````\n",
+		"Actual output:\n\n````\n['```' 1, 2]\n````\n",
+		"Fixing the output again gives:\n\n````\n['```' 1, 2]\n````\n",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("expected the report to contain %q, got:\n%s", expected, report.String())
+		}
+	}
+	if strings.Contains(report.String(), "Expected output") || strings.Contains(report.String(), "Test case") {
+		t.Errorf("expected no reference output, got:\n%s", report.String())
+	}
+}
+
+func TestRunReduce(t *testing.T) {
+	inTempDir(t, func(string) {
+		writeFiles(t, map[string]string{
+			"bug.json": "{\n\t\"name\": \"jsoncomma\"\n\t\"numbers\": [1, 2, -3, 4]\n}\n",
+			"ok.json":  "{\"a\": [1 2]}",
+		})
+		old := *reducePredicate
+		defer func() {
+			*reducePredicate = old
+		}()
+
+		// v1 doesn't know that - starts a number
+		*reducePredicate = "invalid-output"
+		var err error
+		out := withStdio(t, nil, func() {
+			err = runReduce([]string{"bug.json"})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(out), "Found with `jsoncomma reduce -predicate invalid-output -compat v1` (reduced from 51 to ") {
+			t.Errorf("unexpected report:\n%s", out)
+		}
+
+		// nothing to reduce
+		out = withStdio(t, nil, func() {
+			err = runReduce([]string{"ok.json"})
+		})
+		if err == nil || !strings.Contains(err.Error(), "doesn't reproduce invalid-output") || len(out) != 0 {
+			t.Errorf("expected an error and no report, got %v and %q", err, out)
+		}
+
+		*reducePredicate = "typo"
+		if err := runReduce([]string{"bug.json"}); err == nil || !strings.Contains(err.Error(), "unknown predicate") {
+			t.Errorf("expected an unknown predicate error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+var serverCmd = &command{
+	name:  "server",
+	short: "Runs an optimized web server to fix payloads",
+	flags: flag.NewFlagSet("server", flag.ExitOnError),
+}
+
+var serverHost = serverCmd.flags.String("host", "localhost", "Address to bind the server to. \nIf empty, it binds to every interface.")
+
+// note here that we have to explicitely write the "default 0" because go thinks we don't care
+// since 0 is the nil value of an int
+var serverPort = serverCmd.flags.Int("port", 0, "The port to listen on.\n0 means 'chose random unused one' (default 0)")
+var serverCompat = addCompatFlag(serverCmd.flags)
+var serverIdleTimeout = serverCmd.flags.Duration("idle-timeout", 0, "stop after this `duration` without requests (like 30m).\n0 means never")
+var serverParentPid = serverCmd.flags.Int("parent-pid", 0, "stop when the process with this `pid` exits (like the editor which\nstarted the server). 0 means never")
+var serverStdio = serverCmd.flags.Bool("stdio", false, "speak JSON-RPC 2.0 on stdin and stdout instead of HTTP (with Content-Length\nheaders, like LSP). It stops when stdin is closed")
+var serverUnix = serverCmd.flags.String("unix", "", "listen on this unix socket `path` instead of TCP (only the current user\ncan connect). A path starting with @ is an abstract socket (Linux only)")
+
+func init() {
+	serverCmd.run = func(args []string) error {
+		if *serverStdio {
+			incompatible := false
+			serverCmd.flags.Visit(func(f *flag.Flag) {
+				incompatible = incompatible || (f.Name != "stdio" && f.Name != "compat")
+			})
+			if incompatible {
+				return fmt.Errorf("-stdio can only be used with -compat")
+			}
+			return serveStdio(os.Stdin, os.Stdout, *serverCompat)
+		}
+		if *serverUnix != "" {
+			incompatible := false
+			serverCmd.flags.Visit(func(f *flag.Flag) {
+				incompatible = incompatible || f.Name == "host" || f.Name == "port"
+			})
+			if incompatible {
+				return fmt.Errorf("-unix can't be used with -host or -port")
+			}
+		}
+		return serve(serverOptions{
+			host:        *serverHost,
+			port:        *serverPort,
+			unix:        *serverUnix,
+			version:     *serverCompat,
+			idleTimeout: *serverIdleTimeout,
+			parentPid:   *serverParentPid,
+		})
+	}
+}
+
+// serverOptions are the flags of the server
+type serverOptions struct {
+	host string
+	port int
+	// unix is the path of the unix socket to listen on instead of TCP
+	unix string
+	// version is the version of the heuristics used by default
+	version jsoncomma.Version
+	// idleTimeout is how long the server waits for a request before it
+	// stops (0 means forever)
+	idleTimeout time.Duration
+	// parentPid is the process which, when it exits, stops the server (0
+	// means none)
+	parentPid int
+
+	// out is where the events are written (stdout if nil)
+	out io.Writer
+	// signals stop the server (SIGINT and SIGTERM if nil)
+	signals <-chan os.Signal
+	// shutdownTimeout is how long the requests in flight have to finish
+	// (the shutdownTimeout constant if 0)
+	shutdownTimeout time.Duration
+}
+
+type kv map[string]interface{}
+
+// shutdownTimeout is how long the requests in flight have to finish when the
+// server stops
+const shutdownTimeout = 1 * time.Second
+
+// inflight counts the requests being handled, so that stopping the server
+// can wait for them
+type inflight struct {
+	mu      sync.Mutex
+	count   int
+	closing bool
+	// changed is closed (and replaced) every time count decreases
+	changed chan struct{}
+	// lastActive is when the last request started or ended
+	lastActive time.Time
+}
+
+func newInflight() *inflight {
+	return &inflight{
+		changed:    make(chan struct{}),
+		lastActive: time.Now(),
+	}
+}
+
+// track counts the requests to the handler. Once the server is closing, they
+// are refused.
+func (in *inflight) track(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !in.begin() {
+			respondJSON(w, http.StatusServiceUnavailable, kv{
+				"kind": "shutting down",
+				"msg":  "the server is shutting down",
+			})
+			return
+		}
+		defer in.end()
+		handler(w, r)
+	}
+}
+
+func (in *inflight) begin() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closing {
+		return false
+	}
+	in.count++
+	in.lastActive = time.Now()
+	return true
+}
+
+func (in *inflight) end() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.count--
+	in.lastActive = time.Now()
+	close(in.changed)
+	in.changed = make(chan struct{})
+}
+
+// idleSince returns since when there is no request in flight, and false if
+// there is one
+func (in *inflight) idleSince() (time.Time, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.lastActive, in.count == 0
+}
+
+// close refuses the requests from now on
+func (in *inflight) close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.closing = true
+}
+
+// wait waits until there are at most n requests in flight, for at most
+// timeout. It returns true if it timed out.
+func (in *inflight) wait(n int, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		in.mu.Lock()
+		count, changed := in.count, in.changed
+		in.mu.Unlock()
+		if count <= n {
+			return false
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// stopRequest asks the server to stop
+type stopRequest struct {
+	reason string
+	// if it isn't nil, it's given whether the requests in flight timed out
+	// before the server actually stops (the request asking to stop isn't
+	// waited for)
+	timedout chan bool
+}
+
+func serve(opts serverOptions) error {
+
+	// this server fix output send on /
+	// you can shut it down by getting /shutdown, or with SIGINT or SIGTERM.
+	// It also stops by itself after -idle-timeout without requests, or when
+	// the -parent-pid process exits.
+	// The requests in flight get shutdownTimeout to finish, and the new ones
+	// are refused. /shutdown replies with a json object {"timedout": <bool>}
+	// once they are done. If it is true, that means that some requests
+	// didn't finish in time, and were cut off.
+	// The last line written on stdout is {"kind": "stopped", ...}
+
+	// this command should try to only output JSON to stdout
+	out := opts.out
+	if out == nil {
+		out = os.Stdout
+	}
+	encoder := json.NewEncoder(out)
+
+	timeout := opts.shutdownTimeout
+	if timeout == 0 {
+		timeout = shutdownTimeout
+	}
+
+	router := http.NewServeMux()
+
+	server := &http.Server{
+		Handler:      router,
+		ReadTimeout:  time.Minute,
+		WriteTimeout: time.Minute,
+		IdleTimeout:  time.Minute,
+	}
+
+	requests := newInflight()
+
+	// only the first stop request is received, stopping is closed once it is
+	stop := make(chan stopRequest)
+	stopping := make(chan struct{})
+	requestStop := func(reason string) {
+		select {
+		case stop <- stopRequest{reason: reason}:
+		case <-stopping:
+		}
+	}
+
+	router.HandleFunc("/", requests.track(withProtocol(fixHandler(opts.version))))
+
+	// tells whether the body is JSON-like, so that plugins don't have to guess
+	router.HandleFunc("/detect", requests.track(withProtocol(detectHandler)))
+
+	router.HandleFunc("/shutdown", requests.track(withProtocol(func(w http.ResponseWriter, r *http.Request, protocol int) {
+		timedout := make(chan bool, 1)
+		select {
+		case stop <- stopRequest{reason: "shutdown request", timedout: timedout}:
+		case <-stopping:
+			respondJSON(w, http.StatusServiceUnavailable, kv{
+				"kind": "shutting down",
+				"msg":  "the server is already shutting down",
+			})
+			return
+		}
+		// the server waits for this response to be written before it stops
+		respondJSON(w, http.StatusOK, kv{"timedout": <-timedout})
+	})))
+
+	listener, err := listen(opts)
+	if err != nil {
+		if err := encoder.Encode(kv{
+			"kind":    "error",
+			"context": "opening socket",
+			"error":   err.Error(),
+			"details": err,
+		}); err != nil {
+			return err
+		}
+		return encoder.Encode(kv{
+			"kind":     "stopped",
+			"reason":   "opening socket failed",
+			"timedout": false,
+		})
+	}
+
+	signals := opts.signals
+	if signals == nil {
+		notified := make(chan os.Signal, 1)
+		signal.Notify(notified, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(notified)
+		signals = notified
+	}
+	go func() {
+		select {
+		case sig := <-signals:
+			requestStop("signal " + sig.String())
+		case <-stopping:
+		}
+	}()
+	if opts.idleTimeout > 0 {
+		go watchIdle(requests, opts.idleTimeout, requestStop, stopping)
+	}
+	if opts.parentPid != 0 {
+		go watchParent(opts.parentPid, requestStop, stopping)
+	}
+
+	started := kv{
+		"kind":    "started",
+		"network": listener.Addr().Network(),
+		"addr":    listener.Addr().String(),
+		"compat":  opts.version.Resolve().String(),
+		// what the clients can ask for with X-Protocol-Version
+		"protocols":    protocolVersions,
+		"capabilities": capabilitiesByVersion(),
+	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		started["host"] = addr.IP
+		started["port"] = addr.Port
+	}
+	if err := encoder.Encode(started); err != nil {
+		return err
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	// if serving fails, the requests in flight are still given the time to
+	// finish, like for any other stop
+	var req stopRequest
+	serving := true
+	select {
+	case req = <-stop:
+	case err := <-served:
+		serving = false
+		if err := encoder.Encode(kv{
+			"kind":    "error",
+			"context": "serving",
+			"error":   err.Error(),
+			"details": err,
+		}); err != nil {
+			return err
+		}
+		req.reason = "serving failed"
+	}
+	close(stopping)
+
+	requests.close()
+	waitFor := 0
+	if req.timedout != nil {
+		// the request asking to stop is still in flight
+		waitFor = 1
+	}
+	timedout := requests.wait(waitFor, timeout)
+	if req.timedout != nil {
+		req.timedout <- timedout
+	}
+
+	// Shutdown waits for the handlers to return, so the response to
+	// /shutdown is written. The requests which timed out are cut off.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		if !timedout {
+			log.Printf("shutting down server: %s", err)
+		}
+		if err := server.Close(); err != nil {
+			log.Printf("closing server: %s", err)
+		}
+	}
+	if serving {
+		if err := <-served; err != http.ErrServerClosed {
+			log.Printf("serving: %s", err)
+		}
+	}
+
+	return encoder.Encode(kv{
+		"kind":     "stopped",
+		"reason":   req.reason,
+		"timedout": timedout,
+	})
+}
+
+// detectHandler tells whether the body of the requests to /detect is
+// JSON-like
+func detectHandler(w http.ResponseWriter, r *http.Request, protocol int) {
+	if r.Method != http.MethodPost {
+		respondJSON(w, http.StatusMethodNotAllowed, kv{
+			"kind":           "Method not allowed",
+			"msg":            "should only send POST requests to /detect",
+			"current method": r.Method,
+		})
+		return
+	}
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+	defer r.Body.Close()
+
+	detection := jsoncomma.Detect(content)
+	respondJSON(w, http.StatusOK, kv{
+		"jsonlike":   detection.JSONLike,
+		"confidence": detection.Confidence,
+		"reason":     detection.Reason,
+	})
+}
+
+// fixHandler fixes the body of the requests to /, with the heuristics of
+// compat by default
+func fixHandler(compat jsoncomma.Version) func(w http.ResponseWriter, r *http.Request, protocol int) {
+	return func(w http.ResponseWriter, r *http.Request, protocol int) {
+		if r.URL.Path != "/" {
+			respondJSON(w, http.StatusNotFound, kv{
+				"kind":         "not found",
+				"current path": r.URL.Path,
+				"msg":          "should only send requests to /",
+			})
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			respondJSON(w, http.StatusMethodNotAllowed, kv{
+				"kind":           "Method not allowed",
+				"msg":            "should only send POST requests to /",
+				"current method": r.Method,
+			})
+			return
+		}
+
+		// the version of the heuristics is the one given with -compat,
+		// unless the request sets it with ?compat=v1
+		conf := &jsoncomma.Config{
+			Logs:    nil,
+			Version: compat,
+		}
+		if compat := r.URL.Query().Get("compat"); compat != "" {
+			v, err := jsoncomma.ParseVersion(compat)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, kv{
+					"kind": "invalid compat",
+					"msg":  err.Error(),
+				})
+				return
+			}
+			conf.Version = v
+		}
+
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		body := bytes.NewReader(content)
+		defer r.Body.Close()
+
+		// we don't actually know if it's JSON. It's just whatever kind of
+		// text the user gave us that we passed through some filter
+		// the main reason is that the JSON we return may contain
+		// comments etc... Hence it would be wrong to
+		// use a application/json header
+		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+
+		if protocol < 2 {
+			// the output is streamed, so an error cuts it off
+			w.WriteHeader(http.StatusOK)
+			if _, err := jsoncomma.Fix(conf, body, w); err != nil {
+				log.Printf("fixing: %s", err)
+			}
+			return
+		}
+
+		// since 2, the output is only sent once it's complete, with the
+		// number of commas inserted and removed
+		var fixed bytes.Buffer
+		result, err := jsoncomma.Fix(conf, body, &fixed)
+		if err != nil {
+			w.Header().Del("Content-Type")
+			respondJSON(w, http.StatusInternalServerError, kv{
+				"kind": "fix failed",
+				"msg":  err.Error(),
+			})
+			return
+		}
+		w.Header().Set("X-Inserted", strconv.Itoa(result.Inserted))
+		w.Header().Set("X-Removed", strconv.Itoa(result.Removed))
+		w.WriteHeader(http.StatusOK)
+		w.Write(fixed.Bytes())
+	}
+}
+
+func respondJSON(w http.ResponseWriter, code int, obj kv) {
+	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err := enc.Encode(obj)
+	if err != nil {
+		log.Printf("respond json: %s", err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+func TestInflight(t *testing.T) {
+	requests := newInflight()
+	release := make(chan struct{})
+	handler := requests.track(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+		close(done)
+	}()
+	for !requests.wait(0, time.Millisecond) {
+		// the request hasn't started yet
+	}
+
+	requests.close()
+	refused := httptest.NewRecorder()
+	handler(refused, httptest.NewRequest("POST", "/", nil))
+	if refused.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the request to be refused once closing, got status %d", refused.Code)
+	}
+
+	if !requests.wait(0, 10*time.Millisecond) {
+		t.Errorf("expected the wait to time out, the request is still in flight")
+	}
+	if requests.wait(1, 10*time.Millisecond) {
+		t.Errorf("expected the wait for 1 request to return straight away")
+	}
+
+	close(release)
+	if requests.wait(0, time.Second) {
+		t.Errorf("expected the wait to return once the request is done")
+	}
+	<-done
+}
+
+func TestWithProtocol(t *testing.T) {
+	handler := withProtocol(fixHandler(jsoncomma.Default))
+	rows := []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusOK},
+		{"1", http.StatusOK},
+		{"2", http.StatusOK},
+		{"3", http.StatusBadRequest},
+		{"0", http.StatusBadRequest},
+		{"two", http.StatusBadRequest},
+	}
+	for _, row := range rows {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("[1 2,]"))
+		if row.header != "" {
+			r.Header.Set("X-Protocol-Version", row.header)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != row.code {
+			t.Errorf("X-Protocol-Version %q: expected the status %d, got %d (%s)", row.header, row.code, w.Code, w.Body)
+		}
+		if w.Code != http.StatusOK {
+			var response struct {
+				Kind      string
+				Supported []int
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Kind != "unsupported protocol version" || len(response.Supported) != len(protocolVersions) {
+				t.Errorf("X-Protocol-Version %q: unexpected error %s", row.header, w.Body)
+			}
+		}
+	}
+}
+
+func TestFixHandlerProtocols(t *testing.T) {
+	for protocol := range protocolCapabilities {
+		w := httptest.NewRecorder()
+		fixHandler(jsoncomma.Default)(w, httptest.NewRequest("POST", "/", strings.NewReader("[1 2,]")), protocol)
+		if w.Code != http.StatusOK || w.Body.String() != "[1, 2]" {
+			t.Errorf("protocol %d: unexpected response %d %q", protocol, w.Code, w.Body)
+		}
+		inserted, removed := w.Header().Get("X-Inserted"), w.Header().Get("X-Removed")
+		if protocol == 1 && (inserted != "" || removed != "") {
+			t.Errorf("protocol 1 shouldn't have the counts, got %q and %q", inserted, removed)
+		}
+		if protocol >= 2 && (inserted != "1" || removed != "1") {
+			t.Errorf("protocol %d: expected 1 comma inserted and 1 removed, got %q and %q", protocol, inserted, removed)
+		}
+	}
+}
+
+func TestDetectHandler(t *testing.T) {
+	handler := withProtocol(detectHandler)
+	rows := []struct {
+		method   string
+		body     string
+		code     int
+		jsonlike bool
+	}{
+		{"POST", `{"a": [1 2,]}`, http.StatusOK, true},
+		{"POST", "# title\n\nsome *markdown*\n", http.StatusOK, false},
+		{"POST", "", http.StatusOK, false},
+		{"GET", "", http.StatusMethodNotAllowed, false},
+	}
+	for _, row := range rows {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(row.method, "/detect", strings.NewReader(row.body)))
+		if w.Code != row.code {
+			t.Errorf("%s %q: expected the status %d, got %d (%s)", row.method, row.body, row.code, w.Code, w.Body)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var response struct {
+			JSONLike   *bool    `json:"jsonlike"`
+			Confidence *float64 `json:"confidence"`
+			Reason     string   `json:"reason"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.JSONLike == nil || response.Confidence == nil || response.Reason == "" {
+			t.Errorf("%s %q: unexpected response %s (%v)", row.method, row.body, w.Body, err)
+			continue
+		}
+		if *response.JSONLike != row.jsonlike {
+			t.Errorf("%s %q: expected jsonlike %t, got %s", row.method, row.body, row.jsonlike, w.Body)
+		}
+		if *response.Confidence < 0 || *response.Confidence > 1 {
+			t.Errorf("%s %q: confidence out of [0, 1]: %s", row.method, row.body, w.Body)
+		}
+	}
+}
+
+// startServer runs serve in the background. It returns the events it
+// writes, and what it returns once the events are all read.
+func startServer(t *testing.T, opts serverOptions) (<-chan kv, <-chan error) {
+	r, w := io.Pipe()
+	opts.out = w
+	returned := make(chan error, 1)
+	go func() {
+		err := serve(opts)
+		w.Close()
+		returned <- err
+	}()
+
+	events := make(chan kv, 10)
+	go func() {
+		defer close(events)
+		decoder := json.NewDecoder(r)
+		for {
+			var event kv
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	return events, returned
+}
+
+// nextEvent returns the next event of the given kind
+func nextEvent(t *testing.T, events <-chan kv, kind string) kv {
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("expected a %s event, the server stopped writing", kind)
+		}
+		if event["kind"] != kind {
+			t.Fatalf("expected a %s event, got %v", kind, event)
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a %s event", kind)
+	}
+	return nil
+}
+
+// expectStopped checks that the last event is stopped
+func expectStopped(t *testing.T, events <-chan kv, returned <-chan error, reason string, timedout bool) {
+	stopped := nextEvent(t, events, "stopped")
+	if stopped["reason"] != reason || stopped["timedout"] != timedout {
+		t.Errorf("expected the reason %q and timedout %t, got %v", reason, timedout, stopped)
+	}
+	if event, ok := <-events; ok {
+		t.Errorf("expected stopped to be the last event, got %v", event)
+	}
+	if err := <-returned; err != nil {
+		t.Errorf("serve: %s", err)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	events, returned := startServer(t, serverOptions{host: "localhost", signals: make(chan os.Signal)})
+	addr := nextEvent(t, events, "started")["addr"].(string)
+
+	resp, err := http.Post("http://"+addr+"/shutdown", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response kv
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if err != nil || response["timedout"] != false {
+		t.Errorf("expected timedout false, got %v (%v)", response, err)
+	}
+	expectStopped(t, events, returned, "shutdown request", false)
+}
+
+func TestServeSignal(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	events, returned := startServer(t, serverOptions{host: "localhost", signals: signals})
+	nextEvent(t, events, "started")
+
+	signals <- syscall.SIGTERM
+	expectStopped(t, events, returned, "signal terminated", false)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	events, returned := startServer(t, serverOptions{
+		host:            "localhost",
+		signals:         make(chan os.Signal),
+		shutdownTimeout: 50 * time.Millisecond,
+	})
+	addr := nextEvent(t, events, "started")["addr"].(string)
+
+	// a request whose body never comes. The server only says 100 Continue
+	// once the handler reads the body, so the request is in flight.
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: %s\r\nContent-Length: 10\r\nExpect: 100-continue\r\n\r\n", addr)
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.Contains(status, "100") {
+		t.Fatalf("expected 100 Continue, got %q (%v)", status, err)
+	}
+
+	resp, err := http.Post("http://"+addr+"/shutdown", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response kv
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if err != nil || response["timedout"] != true {
+		t.Errorf("expected timedout true, got %v (%v)", response, err)
+	}
+	expectStopped(t, events, returned, "shutdown request", true)
+}
+
+func TestServeListenError(t *testing.T) {
+	events, returned := startServer(t, serverOptions{unix: "/nonexistent/jsoncomma.sock", signals: make(chan os.Signal)})
+	if event := nextEvent(t, events, "error"); event["context"] != "opening socket" {
+		t.Errorf("unexpected error: %v", event)
+	}
+	expectStopped(t, events, returned, "opening socket failed", false)
+}
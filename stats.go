@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// runStats sums up what happened to every file of a run. In verbose mode
+// (-v), it prints a line per file, and a summary at the end.
+type runStats struct {
+	mu      sync.Mutex
+	verbose bool
+	out     io.Writer
+	start   time.Time
+
+	scanned   int
+	changed   int
+	unchanged int
+	failed    int
+	// cached is the number of unchanged files skipped thanks to the cache
+	cached int
+	total  jsoncomma.Result
+}
+
+func newRunStats(verbose bool, out io.Writer) *runStats {
+	return &runStats{
+		verbose: verbose,
+		out:     out,
+		start:   time.Now(),
+	}
+}
+
+// add records what happened to a file. err is the error which stopped it
+// from being processed, if any (it should be logged by the caller). It's
+// safe to call from several goroutines.
+func (s *runStats) add(name string, result jsoncomma.Result, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scanned++
+	if err != nil {
+		s.failed++
+		if s.verbose {
+			fmt.Fprintf(s.out, "%s: failed\n", name)
+		}
+		return
+	}
+
+	s.total = s.total.Add(result)
+	status := "unchanged"
+	if result.Changed() {
+		s.changed++
+		status = "changed"
+	} else {
+		s.unchanged++
+	}
+
+	if s.verbose {
+		fmt.Fprintf(s.out, "%s: %s, %d commas inserted, %d removed (%s)\n", name, status, result.Inserted, result.Removed, formatBytes(result.Read))
+	}
+}
+
+// addCached records a file which was skipped because the cache knows it's
+// fixed
+func (s *runStats) addCached(name string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scanned++
+	s.unchanged++
+	s.cached++
+	s.total.Read += size
+	s.total.Written += size
+
+	if s.verbose {
+		fmt.Fprintf(s.out, "%s: unchanged (cached) (%s)\n", name, formatBytes(size))
+	}
+}
+
+// report prints the summary of the run, in verbose mode only
+func (s *runStats) report() {
+	if !s.verbose {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start)
+	throughput := float64(s.total.Read) / elapsed.Seconds()
+
+	fmt.Fprintf(s.out, "\n%d files scanned: %d changed, %d unchanged (%d cached), %d failed\n", s.scanned, s.changed, s.unchanged, s.cached, s.failed)
+	fmt.Fprintf(s.out, "%d commas inserted, %d removed\n", s.total.Inserted, s.total.Removed)
+	fmt.Fprintf(s.out, "%s processed in %s (%s/s)\n", formatBytes(s.total.Read), elapsed.Round(time.Millisecond), formatBytes(int64(throughput)))
+}
+
+// formatBytes formats a size in a human readable way (1.2 MB)
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
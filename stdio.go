@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// contextReader fails once the context is done, which stops Fix
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// fixParams are the params of the fix and check methods
+type fixParams struct {
+	Text string `json:"text"`
+	// Compat overrides -compat for this request
+	Compat string `json:"compat"`
+}
+
+// config returns the config for the params, compat being the default version
+func (p fixParams) config(compat jsoncomma.Version) (*jsoncomma.Config, error) {
+	config := &jsoncomma.Config{Version: compat}
+	if p.Compat != "" {
+		v, err := jsoncomma.ParseVersion(p.Compat)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		config.Version = v
+	}
+	return config, nil
+}
+
+// serveStdio speaks JSON-RPC 2.0 on stdin and stdout (which are only used
+// for the protocol's messages), until the shutdown method is called or stdin
+// is closed. The methods are:
+//
+//	fix      {"text": "...", "compat": "v1"} -> {"text": "...", "inserted": 1, "removed": 0}
+//	check    {"text": "...", "compat": "v1"} -> {"fixed": false, "inserted": 1, "removed": 0}
+//	version  -> {"version": "...", "commit": "...", "date": "...", "compat": "v1", "versions": [...]}
+//	shutdown -> null
+func serveStdio(in io.Reader, out io.Writer, compat jsoncomma.Version) error {
+	s := newRPCServer(newRPCConn(in, out))
+
+	s.requests["fix"] = func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params fixParams
+		if err := unmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		config, err := params.config(compat)
+		if err != nil {
+			return nil, err
+		}
+		var fixed strings.Builder
+		result, err := jsoncomma.Fix(config, contextReader{ctx, strings.NewReader(params.Text)}, &fixed)
+		if err != nil {
+			return nil, err
+		}
+		return kv{
+			"text":     fixed.String(),
+			"inserted": result.Inserted,
+			"removed":  result.Removed,
+		}, nil
+	}
+
+	s.requests["check"] = func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params fixParams
+		if err := unmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		config, err := params.config(compat)
+		if err != nil {
+			return nil, err
+		}
+		result, err := jsoncomma.Fix(config, contextReader{ctx, strings.NewReader(params.Text)}, ioutil.Discard)
+		if err != nil {
+			return nil, err
+		}
+		return kv{
+			"fixed":    !result.Changed(),
+			"inserted": result.Inserted,
+			"removed":  result.Removed,
+		}, nil
+	}
+
+	s.requests["version"] = func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		versions := make([]string, len(jsoncomma.Versions))
+		for i, v := range jsoncomma.Versions {
+			versions[i] = v.String()
+		}
+		return kv{
+			"version":  version,
+			"commit":   commit,
+			"date":     date,
+			"compat":   compat.Resolve().String(),
+			"versions": versions,
+		}, nil
+	}
+
+	s.requests["shutdown"] = func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		s.stop()
+		return nil, nil
+	}
+	s.inline["shutdown"] = true
+
+	return s.serve()
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// tomlEmitter writes TOML. Objects become tables and arrays of objects
+// arrays of tables, except inside other arrays, where they are inline.
+//
+// TOML requires the key/value pairs of a table to come before its
+// sub-tables, so the key order is kept within each of those two groups.
+type tomlEmitter struct {
+	buf bytes.Buffer
+}
+
+func emitTOML(doc *jsoncomma.Document) ([]byte, error) {
+	root := doc.Root
+	if root.Kind != jsoncomma.Object {
+		return nil, fmt.Errorf("a TOML document is a table, it can't be a top-level %s", root.Kind)
+	}
+
+	e := &tomlEmitter{}
+	e.comments("", root.Comments)
+	e.lineCommentAbove(root)
+	if err := e.table(root, "", ""); err != nil {
+		return nil, err
+	}
+	e.comments("", doc.Trailing)
+	return e.buf.Bytes(), nil
+}
+
+func (e *tomlEmitter) comments(indent string, comments []jsoncomma.Comment) {
+	for _, comment := range comments {
+		for _, line := range comment.Lines() {
+			e.buf.WriteString(strings.TrimRight(indent+"# "+line, " ") + "\n")
+		}
+	}
+}
+
+func (e *tomlEmitter) lineComment(v *jsoncomma.Value) {
+	if v.LineComment != nil {
+		e.buf.WriteString(" # " + strings.Join(v.LineComment.Lines(), " "))
+	}
+	e.buf.WriteByte('\n')
+}
+
+func (e *tomlEmitter) lineCommentAbove(v *jsoncomma.Value) {
+	if v.LineComment != nil {
+		e.comments("", []jsoncomma.Comment{*v.LineComment})
+	}
+}
+
+// header starts a new table
+func (e *tomlEmitter) header(comments []jsoncomma.Comment, header string) {
+	if e.buf.Len() > 0 {
+		e.buf.WriteByte('\n')
+	}
+	e.comments("", comments)
+	e.buf.WriteString(header)
+}
+
+// isArrayOfTables returns true if v is written as [[array.of.tables]]
+func isArrayOfTables(v *jsoncomma.Value) bool {
+	if v.Kind != jsoncomma.Array || len(v.Elements) == 0 {
+		return false
+	}
+	for _, element := range v.Elements {
+		if element.Kind != jsoncomma.Object {
+			return false
+		}
+	}
+	return true
+}
+
+// table writes the content of the table v, whose dotted key is prefix
+// (empty for the root), and whose JSON pointer is path
+func (e *tomlEmitter) table(v *jsoncomma.Value, prefix, path string) error {
+	for _, member := range v.Members {
+		if member.Value.Kind == jsoncomma.Object || isArrayOfTables(member.Value) {
+			continue
+		}
+		value, err := e.inline(member.Value, pointerPath(path, member.Key), "")
+		if err != nil {
+			return err
+		}
+		e.comments("", member.Value.Comments)
+		e.buf.WriteString(tomlKey(member.Key) + " = " + value)
+		e.lineComment(member.Value)
+	}
+	e.comments("", v.Inner)
+
+	for _, member := range v.Members {
+		key := tomlKey(member.Key)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		memberPath := pointerPath(path, member.Key)
+
+		if member.Value.Kind == jsoncomma.Object {
+			e.header(member.Value.Comments, "["+key+"]")
+			e.lineComment(member.Value)
+			if err := e.table(member.Value, key, memberPath); err != nil {
+				return err
+			}
+		} else if isArrayOfTables(member.Value) {
+			for i, element := range member.Value.Elements {
+				comments := element.Comments
+				if i == 0 {
+					comments = append(member.Value.Comments, comments...)
+				}
+				e.header(comments, "[["+key+"]]")
+				e.lineComment(element)
+				if err := e.table(element, key, fmt.Sprintf("%s/%d", memberPath, i)); err != nil {
+					return err
+				}
+			}
+			e.comments("", member.Value.Inner)
+		}
+	}
+	return nil
+}
+
+// inline returns v as an inline value. Arrays with comments span several
+// lines (the following ones start with indent), since inline tables can't
+// have comments.
+func (e *tomlEmitter) inline(v *jsoncomma.Value, path, indent string) (string, error) {
+	switch v.Kind {
+	case jsoncomma.Null:
+		return "", fmt.Errorf("%s is null, TOML has no null", displayPointer(path))
+
+	case jsoncomma.Bool:
+		return v.Raw, nil
+
+	case jsoncomma.Number:
+		if !strings.ContainsAny(v.Raw, ".eE") {
+			if _, err := strconv.ParseInt(v.Raw, 10, 64); err != nil {
+				return "", fmt.Errorf("%s is %s, which doesn't fit in a TOML integer (64 bit)", displayPointer(path), v.Raw)
+			}
+		}
+		// the syntax of JSON numbers is a subset of TOML's
+		return v.Raw, nil
+
+	case jsoncomma.String:
+		s, err := v.Str()
+		if err != nil {
+			return "", err
+		}
+		return tomlString(s), nil
+
+	case jsoncomma.Object:
+		members := make([]string, len(v.Members))
+		for i, member := range v.Members {
+			value, err := e.inline(member.Value, pointerPath(path, member.Key), indent)
+			if err != nil {
+				return "", err
+			}
+			members[i] = tomlKey(member.Key) + " = " + value
+		}
+		if len(members) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(members, ", ") + " }", nil
+	}
+
+	multiline := len(v.Inner) > 0
+	for _, element := range v.Elements {
+		if len(element.Comments) > 0 || element.LineComment != nil {
+			multiline = true
+		}
+	}
+
+	elements := make([]string, len(v.Elements))
+	for i, element := range v.Elements {
+		value, err := e.inline(element, fmt.Sprintf("%s/%d", path, i), indent+"\t")
+		if err != nil {
+			return "", err
+		}
+		elements[i] = value
+	}
+	if !multiline {
+		return "[" + strings.Join(elements, ", ") + "]", nil
+	}
+
+	// written with its own emitter, to reuse the comment helpers
+	array := &tomlEmitter{}
+	array.buf.WriteString("[\n")
+	for i, element := range v.Elements {
+		array.comments(indent+"\t", element.Comments)
+		array.buf.WriteString(indent + "\t" + elements[i] + ",")
+		array.lineComment(element)
+	}
+	array.comments(indent+"\t", v.Inner)
+	array.buf.WriteString(indent + "]")
+	return array.buf.String(), nil
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString returns s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// parentCheckInterval is how often watchParent checks the parent process
+const parentCheckInterval = time.Second
+
+// watchIdle stops the server once no request was made for timeout
+func watchIdle(requests *inflight, timeout time.Duration, stop func(reason string), stopping <-chan struct{}) {
+	for {
+		wait := timeout
+		if since, idle := requests.idleSince(); idle {
+			wait = timeout - time.Since(since)
+			if wait <= 0 {
+				stop(fmt.Sprintf("idle for %s", timeout))
+				return
+			}
+		}
+		select {
+		case <-time.After(wait):
+		case <-stopping:
+			return
+		}
+	}
+}
+
+// watchParent stops the server once the process pid exits
+func watchParent(pid int, stop func(reason string), stopping <-chan struct{}) {
+	ticker := time.NewTicker(parentCheckInterval)
+	defer ticker.Stop()
+	for {
+		if !processExists(pid) {
+			stop(fmt.Sprintf("parent process %d exited", pid))
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stopping:
+			return
+		}
+	}
+}
+
+// processExists returns true if the process is running. On Linux, it reads
+// /proc (zombies are dead), elsewhere it asks the OS.
+func processExists(pid int) bool {
+	if runtime.GOOS == "linux" {
+		stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return false
+		}
+		// pid (command) state ..., the command can contain parentheses
+		end := bytes.LastIndexByte(stat, ')')
+		return end < 0 || !bytes.HasPrefix(stat[end+1:], []byte(" Z"))
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails if the process doesn't exist
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	var errno syscall.Errno
+	// EPERM means it exists, but belongs to someone else
+	return err == nil || (errors.As(err, &errno) && errno == syscall.EPERM)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestProcessExists(t *testing.T) {
+	if !processExists(os.Getpid()) {
+		t.Errorf("expected the current process to exist")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if processExists(cmd.Process.Pid) {
+		t.Errorf("expected the exited process %d not to exist", cmd.Process.Pid)
+	}
+}
+
+func TestWatchIdle(t *testing.T) {
+	requests := newInflight()
+	reasons := make(chan string, 1)
+	stopping := make(chan struct{})
+	defer close(stopping)
+
+	requests.begin()
+	go watchIdle(requests, 20*time.Millisecond, func(reason string) {
+		reasons <- reason
+	}, stopping)
+
+	select {
+	case <-reasons:
+		t.Fatalf("stopped while a request was in flight")
+	case <-time.After(60 * time.Millisecond):
+	}
+
+	requests.end()
+	select {
+	case reason := <-reasons:
+		if reason != "idle for 20ms" {
+			t.Errorf("unexpected reason %q", reason)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("still running a second after the last request")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	jsoncomma "github.com/jsoncomma/jsoncomma/internals"
+)
+
+// yamlEmitter writes block style YAML. Strings are always double quoted
+// (JSON strings are valid YAML), so they can't be mistaken for something
+// else (yes, 1.0, etc).
+type yamlEmitter struct {
+	buf bytes.Buffer
+}
+
+func emitYAML(doc *jsoncomma.Document) ([]byte, error) {
+	e := &yamlEmitter{}
+	root := doc.Root
+
+	e.comments(0, root.Comments)
+	var err error
+	switch {
+	case root.Kind == jsoncomma.Object && len(root.Members) > 0:
+		e.lineCommentAbove(0, root)
+		err = e.mapping(root, 0, "")
+	case root.Kind == jsoncomma.Array && len(root.Elements) > 0:
+		e.lineCommentAbove(0, root)
+		err = e.sequence(root, 0, "")
+	default:
+		err = e.scalar("", root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.comments(0, doc.Trailing)
+	return e.buf.Bytes(), nil
+}
+
+func pad(indent int) string {
+	return strings.Repeat(" ", indent)
+}
+
+// indentOf returns the number of spaces lead starts with
+func indentOf(lead string) int {
+	return len(lead) - len(strings.TrimLeft(lead, " "))
+}
+
+func (e *yamlEmitter) comments(indent int, comments []jsoncomma.Comment) {
+	for _, comment := range comments {
+		for _, line := range comment.Lines() {
+			e.buf.WriteString(strings.TrimRight(pad(indent)+"# "+line, " ") + "\n")
+		}
+	}
+}
+
+// lineComment writes the comment of the value at the end of the current line
+func (e *yamlEmitter) lineComment(v *jsoncomma.Value) {
+	if v.LineComment != nil {
+		e.buf.WriteString(" # " + strings.Join(v.LineComment.Lines(), " "))
+	}
+	e.buf.WriteByte('\n')
+}
+
+// lineCommentAbove writes the line comment of a value which spans several
+// lines on its own line, above it
+func (e *yamlEmitter) lineCommentAbove(indent int, v *jsoncomma.Value) {
+	if v.LineComment != nil {
+		e.comments(indent, []jsoncomma.Comment{*v.LineComment})
+	}
+}
+
+// mapping writes the members of v, the first line starting with lead, and
+// the other ones with indent spaces
+func (e *yamlEmitter) mapping(v *jsoncomma.Value, indent int, lead string) error {
+	for i, member := range v.Members {
+		if i > 0 {
+			lead = pad(indent)
+		}
+		e.comments(indentOf(lead), member.Value.Comments)
+		e.buf.WriteString(lead + yamlKey(member.Key) + ":")
+		if err := e.nested(member.Value, indent); err != nil {
+			return err
+		}
+	}
+	e.comments(indent, v.Inner)
+	return nil
+}
+
+// sequence writes the elements of v, the first line starting with lead,
+// and the other ones with indent spaces
+func (e *yamlEmitter) sequence(v *jsoncomma.Value, indent int, lead string) error {
+	for i, element := range v.Elements {
+		if i > 0 {
+			lead = pad(indent)
+		}
+		e.comments(indentOf(lead), element.Comments)
+
+		var err error
+		switch {
+		case element.Kind == jsoncomma.Object && len(element.Members) > 0:
+			e.lineCommentAbove(indentOf(lead), element)
+			err = e.mapping(element, indent+2, lead+"- ")
+		case element.Kind == jsoncomma.Array && len(element.Elements) > 0:
+			e.lineCommentAbove(indentOf(lead), element)
+			err = e.sequence(element, indent+2, lead+"- ")
+		default:
+			err = e.scalar(lead+"- ", element)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	e.comments(indent, v.Inner)
+	return nil
+}
+
+// nested writes the value of a member, after the "key:" which is indented
+// with indent spaces
+func (e *yamlEmitter) nested(v *jsoncomma.Value, indent int) error {
+	switch {
+	case v.Kind == jsoncomma.Object && len(v.Members) > 0:
+		e.lineComment(v)
+		return e.mapping(v, indent+2, pad(indent+2))
+	case v.Kind == jsoncomma.Array && len(v.Elements) > 0:
+		e.lineComment(v)
+		return e.sequence(v, indent+2, pad(indent+2))
+	}
+	return e.scalar(" ", v)
+}
+
+// scalar writes a value which fits on a line (including empty objects and
+// arrays) after lead
+func (e *yamlEmitter) scalar(lead string, v *jsoncomma.Value) error {
+	e.buf.WriteString(lead)
+	switch v.Kind {
+	case jsoncomma.String:
+		s, err := v.Str()
+		if err != nil {
+			return err
+		}
+		e.buf.WriteString(jsonString(s))
+	case jsoncomma.Object:
+		e.buf.WriteString("{}")
+	case jsoncomma.Array:
+		e.buf.WriteString("[]")
+	default:
+		e.buf.WriteString(v.Raw)
+	}
+	e.lineComment(v)
+	return nil
+}
+
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// yamlKey quotes the key unless it's unambiguous as a plain scalar
+func yamlKey(key string) string {
+	if !yamlPlainKey.MatchString(key) {
+		return jsonString(key)
+	}
+	switch strings.ToLower(key) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n":
+		return jsonString(key)
+	}
+	return key
+}